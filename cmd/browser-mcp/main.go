@@ -13,8 +13,14 @@ import (
 )
 
 var (
-	transport string
-	port      string
+	transport    string
+	port         string
+	dataDir      string
+	profilesDir  string
+	profile      string
+	ephemeral    bool
+	downloadsDir string
+	uploadDirs   string
 )
 
 func init() {
@@ -22,6 +28,12 @@ func init() {
 	flag.StringVar(&transport, "transport", "stdio", "Transport type (stdio or sse)")
 	flag.StringVar(&port, "p", "8080", "Port to listen on")
 	flag.StringVar(&port, "port", "8080", "Port to listen on")
+	flag.StringVar(&dataDir, "data-dir", "", "Directory for saved artifacts")
+	flag.StringVar(&profilesDir, "profiles-dir", "", "Directory of the named browser profiles")
+	flag.StringVar(&profile, "profile", "", "Name of the browser profile to start with")
+	flag.BoolVar(&ephemeral, "ephemeral", false, "Use a throwaway browser profile")
+	flag.StringVar(&downloadsDir, "downloads-dir", "", "Directory downloads are saved to")
+	flag.StringVar(&uploadDirs, "upload-dirs", "", "Comma separated directories files may be uploaded from")
 }
 
 func main() {
@@ -39,11 +51,31 @@ func main() {
 	)
 
 	ctx := context.Background()
-	bs, err := service.NewBrowserService(ctx, []string{})
+	var args []string
+	if dataDir != "" {
+		args = append(args, "-data-dir", dataDir)
+	}
+	if profilesDir != "" {
+		args = append(args, "-profiles-dir", profilesDir)
+	}
+	if profile != "" {
+		args = append(args, "-profile", profile)
+	}
+	if ephemeral {
+		args = append(args, "-ephemeral")
+	}
+	if downloadsDir != "" {
+		args = append(args, "-downloads-dir", downloadsDir)
+	}
+	if uploadDirs != "" {
+		args = append(args, "-upload-dirs", uploadDirs)
+	}
+	bs, err := service.NewBrowserService(ctx, args)
 	if err != nil {
 		slog.Error("Failed to create browser service", "error", err)
 		os.Exit(1)
 	}
+	defer bs.Close()
 
 	s.AddTools(bs.Tools()...)
 
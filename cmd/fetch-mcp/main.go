@@ -2,33 +2,61 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log/slog"
 	"os"
 
 	"github.com/dyike/MonoMCPHub/internal/fetch"
+	"github.com/dyike/MonoMCPHub/internal/fetch/config"
 	"github.com/mark3labs/mcp-go/server"
 )
 
+var (
+	configPath string
+)
+
+func init() {
+	flag.StringVar(&configPath, "c", "", "Path to the JSON config file")
+	flag.StringVar(&configPath, "config", "", "Path to the JSON config file")
+}
+
 func main() {
+	flag.Parse()
+
 	logLevel := slog.LevelDebug
 	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
 		Level: logLevel,
 	})))
 
 	slog.Info("Starting browser mcp server")
+	// resources/subscribe is not routed by mcp-go, so subscribe is not
+	// advertised; reading a fetch:// or fetch-md:// resource is what
+	// subscribes a session to its notifications/resources/updated. Pages are
+	// only served through the resource templates, so the list never changes.
 	s := server.NewMCPServer(
 		"fetch mcp server",
 		"0.0.1",
+		server.WithResourceCapabilities(false, false),
 	)
 
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		slog.Error("Failed to load config", "error", err)
+		os.Exit(1)
+	}
+
 	ctx := context.Background()
-	fs := fetch.NewFetchService(ctx)
+	fs := fetch.NewFetchService(ctx, cfg)
 	if fs == nil {
 		slog.Error("Failed to create fetch service")
 		os.Exit(1)
 	}
+	defer fs.Close()
 
 	s.AddTools(fs.Tools()...)
+	for rt, rtf := range fs.ResourceTemplates() {
+		s.AddResourceTemplate(rt, rtf)
+	}
 
 	if err := server.ServeStdio(s); err != nil {
 		slog.Error("Failed to serve", "error", err)
@@ -1,5 +1,12 @@
 package config
 
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
 type BrowserConfig struct {
 	Headless        bool
 	Timeout         int
@@ -8,7 +15,22 @@ type BrowserConfig struct {
 	DefaultLanguage string
 	URLTimeout      int
 	CSSTimeout      int
-	DataPath        string
+	// DataPath is where artifacts such as saved screenshots are written
+	DataPath string
+	// ProfilesPath holds one Chrome user data directory per named profile,
+	// default: DataPath/profiles
+	ProfilesPath string
+	// Profile is the profile the browser starts with
+	Profile string
+	// Ephemeral starts the browser on a throwaway profile that is removed
+	// when the browser is closed
+	Ephemeral bool
+	// DownloadsPath is where downloads started by pages are saved, default:
+	// DataPath/downloads
+	DownloadsPath string
+	// UploadPaths are the directories browser_upload may take files from,
+	// default: DataPath
+	UploadPaths []string
 }
 
 func NewBrowserConfig() *BrowserConfig {
@@ -19,6 +41,61 @@ func NewBrowserConfig() *BrowserConfig {
 		DefaultLanguage: "zh-CN",
 		URLTimeout:      30,
 		CSSTimeout:      30,
-		DataPath:        "/Users/ityike/.browser_data",
+		DataPath:        defaultDataPath(),
+		Profile:         "default",
+	}
+}
+
+func defaultDataPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "browser_mcp")
 	}
+	return filepath.Join(home, ".browser_data")
+}
+
+// ProfilesDir returns the directory that holds the named profiles
+func (c *BrowserConfig) ProfilesDir() string {
+	if c.ProfilesPath != "" {
+		return c.ProfilesPath
+	}
+	return filepath.Join(c.DataPath, "profiles")
+}
+
+// DownloadsDir returns the directory downloads are saved to
+func (c *BrowserConfig) DownloadsDir() string {
+	if c.DownloadsPath != "" {
+		return c.DownloadsPath
+	}
+	return filepath.Join(c.DataPath, "downloads")
+}
+
+// UploadDirs returns the directories files may be uploaded from
+func (c *BrowserConfig) UploadDirs() []string {
+	if len(c.UploadPaths) > 0 {
+		return c.UploadPaths
+	}
+	return []string{c.DataPath}
+}
+
+// ParseArgs applies command line style options to the config:
+// -data-dir, -profiles-dir, -profile, -ephemeral, -downloads-dir and
+// -upload-dirs, a comma separated list.
+func (c *BrowserConfig) ParseArgs(args []string) error {
+	fs := flag.NewFlagSet("browser", flag.ContinueOnError)
+	fs.StringVar(&c.DataPath, "data-dir", c.DataPath, "Directory for saved artifacts")
+	fs.StringVar(&c.ProfilesPath, "profiles-dir", c.ProfilesPath, "Directory of the named browser profiles")
+	fs.StringVar(&c.Profile, "profile", c.Profile, "Name of the browser profile to start with")
+	fs.BoolVar(&c.Ephemeral, "ephemeral", c.Ephemeral, "Use a throwaway browser profile")
+	fs.StringVar(&c.DownloadsPath, "downloads-dir", c.DownloadsPath, "Directory downloads are saved to")
+	fs.Func("upload-dirs", "Comma separated directories files may be uploaded from", func(v string) error {
+		c.UploadPaths = nil
+		for _, dir := range strings.Split(v, ",") {
+			if dir = strings.TrimSpace(dir); dir != "" {
+				c.UploadPaths = append(c.UploadPaths, dir)
+			}
+		}
+		return nil
+	})
+	return fs.Parse(args)
 }
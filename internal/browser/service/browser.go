@@ -2,11 +2,15 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
-	"path/filepath"
-	"time"
+	"sync"
 
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/cdproto/runtime"
 	"github.com/chromedp/chromedp"
 	"github.com/dyike/MonoMCPHub/internal/browser/config"
 	sv "github.com/dyike/MonoMCPHub/pkg/service"
@@ -17,31 +21,37 @@ type BrowserService struct {
 	sv.ServiceManager
 	config *config.BrowserConfig
 	name   string
-	ctx    context.Context
-	cancel context.CancelFunc
+	// baseCtx is the service context every browser is started from
+	baseCtx context.Context
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu      sync.Mutex
+	profile *browserProfile
+	started bool
+	tabs    map[string]*tab
+	tabSeq  int
+	current string
+
+	downloads *downloads
 }
 
 func NewBrowserService(ctx context.Context, args []string) (sv.Service, error) {
 	bconf := config.NewBrowserConfig()
+	if err := bconf.ParseArgs(args); err != nil {
+		return nil, err
+	}
 	bs := &BrowserService{
-		ctx:    ctx,
-		config: bconf,
-		name:   "browser_mcp",
+		baseCtx: ctx,
+		config:  bconf,
+		name:    "browser_mcp",
+		tabs:    make(map[string]*tab),
 	}
 	bs.ServiceManager = *sv.NewServiceManager(ctx)
-	err := bs.initBrowser(bconf.DataPath)
+	err := bs.initBrowser()
 	if err != nil {
 		return nil, fmt.Errorf("failed to init browser: %v", err)
 	}
-	opts := append(chromedp.DefaultExecAllocatorOptions[:],
-		chromedp.UserAgent(bconf.UserAgent),
-		chromedp.Flag("headless", bconf.Headless),
-		chromedp.Flag("lang", bconf.DefaultLanguage),
-		chromedp.WindowSize(1312, 848),
-	)
-
-	bs.ctx, bs.cancel = chromedp.NewExecAllocator(ctx, opts...)
-	bs.ctx, bs.cancel = chromedp.NewContext(bs.ctx)
 
 	bs.AddTool(mcp.NewTool(
 		"browser_navigate",
@@ -50,33 +60,22 @@ func NewBrowserService(ctx context.Context, args []string) (sv.Service, error) {
 			mcp.Required(),
 			mcp.Description("The URL to navigate to"),
 		),
+		waitUntilArg(),
+		timeoutArg(bconf.URLTimeout),
+		tabArg(),
 	), bs.handleNavigate)
 
-	bs.AddTool(mcp.NewTool(
-		"browser_screenshot",
-		mcp.WithDescription("Take a screenshot of the current page"),
-		mcp.WithString("name",
-			mcp.Description("The name of the screenshot"),
-			mcp.Required(),
-		),
-		mcp.WithString("selector",
-			mcp.Description("The CSS selector of the element to screenshot"),
-		),
-		mcp.WithNumber("width",
-			mcp.Description("The width of the screenshot in pixels, default: 1600"),
-		),
-		mcp.WithNumber("height",
-			mcp.Description("The height of the screenshot in pixels, default: 1000"),
-		),
-	), bs.handleScreenshot)
+	bs.AddTool(bs.newScreenshotTool(), bs.handleScreenshot)
 
 	bs.AddTool(mcp.NewTool(
 		"browser_click",
 		mcp.WithDescription("Click on an element on the page"),
 		mcp.WithString("selector",
 			mcp.Description("The CSS selector of the element to click on"),
-			mcp.Required(),
 		),
+		refArg(),
+		timeoutArg(bconf.CSSTimeout),
+		tabArg(),
 	), bs.handleClick)
 
 	bs.AddTool(mcp.NewTool(
@@ -84,12 +83,14 @@ func NewBrowserService(ctx context.Context, args []string) (sv.Service, error) {
 		mcp.WithDescription("Fill an input with a value"),
 		mcp.WithString("selector",
 			mcp.Description("The CSS selector of the input to fill"),
-			mcp.Required(),
 		),
+		refArg(),
 		mcp.WithString("value",
 			mcp.Description("The value to fill the input with"),
 			mcp.Required(),
 		),
+		timeoutArg(bconf.CSSTimeout),
+		tabArg(),
 	), bs.handleFill)
 
 	bs.AddTool(mcp.NewTool(
@@ -97,12 +98,14 @@ func NewBrowserService(ctx context.Context, args []string) (sv.Service, error) {
 		mcp.WithDescription("Select an element on the page with selector tag"),
 		mcp.WithString("selector",
 			mcp.Description("The CSS selector for element to select"),
-			mcp.Required(),
 		),
+		refArg(),
 		mcp.WithString("value",
 			mcp.Description("The value to select"),
 			mcp.Required(),
 		),
+		timeoutArg(bconf.CSSTimeout),
+		tabArg(),
 	), bs.handleSelect)
 
 	bs.AddTool(mcp.NewTool(
@@ -110,8 +113,10 @@ func NewBrowserService(ctx context.Context, args []string) (sv.Service, error) {
 		mcp.WithDescription("Hover over an element on the page"),
 		mcp.WithString("selector",
 			mcp.Description("The CSS selector for element to hover over"),
-			mcp.Required(),
 		),
+		refArg(),
+		timeoutArg(bconf.CSSTimeout),
+		tabArg(),
 	), bs.handleHover)
 
 	bs.AddTool(mcp.NewTool(
@@ -121,8 +126,21 @@ func NewBrowserService(ctx context.Context, args []string) (sv.Service, error) {
 			mcp.Description("The JavaScript code to execute"),
 			mcp.Required(),
 		),
+		timeoutArg(bconf.Timeout),
+		tabArg(),
 	), bs.handleEvaluate)
 
+	bs.registerTabTools()
+	bs.registerSnapshotTool()
+	bs.registerProfileTools()
+	bs.registerWaitTool()
+	bs.registerCaptureTools()
+	bs.registerHARTool()
+	bs.registerPDFTool()
+	bs.registerUploadTool()
+	bs.registerDownloadTool()
+	bs.registerInputTools()
+
 	return bs, nil
 }
 
@@ -131,71 +149,21 @@ func (bs *BrowserService) handleNavigate(ctx context.Context, request mcp.CallTo
 	if !ok {
 		return nil, fmt.Errorf("url must be a string")
 	}
-	err := chromedp.Run(bs.ctx, chromedp.Navigate(url))
+	t, err := bs.tabOf(request)
 	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Failed to navigate to %s: %v", url, err),
-				},
-			},
-			IsError: true,
-		}, nil
-	}
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			mcp.TextContent{
-				Type: "text",
-				Text: fmt.Sprintf("Navigated to %s", url),
-			},
-		},
-	}, nil
-}
-
-func (bs *BrowserService) handleScreenshot(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	name, ok := request.Params.Arguments["name"].(string)
-	if !ok {
-		return nil, fmt.Errorf("name must be a string")
-	}
-	selector, ok := request.Params.Arguments["selector"].(string)
-	width, _ := request.Params.Arguments["width"].(float64)
-	height, _ := request.Params.Arguments["height"].(float64)
-
-	if width == 0 {
-		width = 1600
-	}
-	if height == 0 {
-		height = 1000
-	}
-
-	var buf []byte
-	var err error
-	if selector == "" {
-		err = chromedp.Run(bs.ctx, chromedp.FullScreenshot(&buf, 90))
-	} else {
-		// TODO: add width and height
-		err = chromedp.Run(bs.ctx, chromedp.Screenshot(selector, &buf, chromedp.NodeVisible, chromedp.ByQuery))
+		return mcp.NewToolResultError(err.Error()), nil
 	}
+	navigate, err := navigateAction(url, request.Params.Arguments)
 	if err != nil {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "text",
-					Text: fmt.Sprintf("Failed to take screenshot: %v", err),
-				},
-			},
-			IsError: true,
-		}, nil
+		return mcp.NewToolResultError(err.Error()), nil
 	}
-	newName := filepath.Join(bs.config.DataPath, fmt.Sprintf("%s_%d.png", name, time.Now().Unix()))
-	err = os.WriteFile(newName, buf, 0644)
+	err = bs.run(ctx, t, timeout(request.Params.Arguments, bs.config.URLTimeout), navigate)
 	if err != nil {
 		return &mcp.CallToolResult{
 			Content: []mcp.Content{
 				mcp.TextContent{
 					Type: "text",
-					Text: fmt.Sprintf("Failed to save screenshot: %v", err),
+					Text: fmt.Sprintf("Failed to navigate to %s: %v", url, err),
 				},
 			},
 			IsError: true,
@@ -205,28 +173,32 @@ func (bs *BrowserService) handleScreenshot(ctx context.Context, request mcp.Call
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
-				Text: fmt.Sprintf("Screenshot saved to %s", newName),
+				Text: fmt.Sprintf("Navigated to %s", url),
 			},
 		},
 	}, nil
 }
 
 func (bs *BrowserService) handleClick(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	selector, ok := request.Params.Arguments["selector"].(string)
 	result := &mcp.CallToolResult{
 		IsError: false,
 	}
-	if !ok {
+	t, err := bs.tabOf(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	sel, opts, selector, err := bs.elementSelector(ctx, t, request.Params.Arguments, timeout(request.Params.Arguments, bs.config.CSSTimeout))
+	if err != nil {
 		result.IsError = true
 		result.Content = []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
-				Text: "selector must be a string",
+				Text: err.Error(),
 			},
 		}
 		return result, nil
 	}
-	err := chromedp.Run(bs.ctx, chromedp.Click(selector, chromedp.NodeVisible, chromedp.ByQuery))
+	err = bs.run(ctx, t, timeout(request.Params.Arguments, bs.config.CSSTimeout), chromedp.Click(sel, append(opts, chromedp.NodeVisible)...))
 	if err != nil {
 		result.IsError = true
 		result.Content = []mcp.Content{
@@ -247,15 +219,19 @@ func (bs *BrowserService) handleClick(ctx context.Context, request mcp.CallToolR
 }
 
 func (bs *BrowserService) handleFill(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	selector, ok := request.Params.Arguments["selector"].(string)
-	if !ok {
-		return nil, fmt.Errorf("selector must be a string")
-	}
 	value, ok := request.Params.Arguments["value"].(string)
 	if !ok {
 		return nil, fmt.Errorf("value must be a string")
 	}
-	err := chromedp.Run(bs.ctx, chromedp.SendKeys(selector, value, chromedp.NodeVisible, chromedp.ByQuery))
+	t, err := bs.tabOf(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	sel, opts, selector, err := bs.elementSelector(ctx, t, request.Params.Arguments, timeout(request.Params.Arguments, bs.config.CSSTimeout))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	err = bs.run(ctx, t, timeout(request.Params.Arguments, bs.config.CSSTimeout), chromedp.SendKeys(sel, value, append(opts, chromedp.NodeVisible)...))
 	if err != nil {
 		return nil, fmt.Errorf("failed to fill %s with %s: %v", selector, value, err)
 	}
@@ -270,15 +246,19 @@ func (bs *BrowserService) handleFill(ctx context.Context, request mcp.CallToolRe
 }
 
 func (bs *BrowserService) handleSelect(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	selector, ok := request.Params.Arguments["selector"].(string)
-	if !ok {
-		return nil, fmt.Errorf("selector must be a string")
-	}
 	value, ok := request.Params.Arguments["value"].(string)
 	if !ok {
 		return nil, fmt.Errorf("value must be a string")
 	}
-	err := chromedp.Run(bs.ctx, chromedp.SetValue(selector, value, chromedp.NodeVisible, chromedp.ByQuery))
+	t, err := bs.tabOf(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	sel, opts, selector, err := bs.elementSelector(ctx, t, request.Params.Arguments, timeout(request.Params.Arguments, bs.config.CSSTimeout))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	err = bs.run(ctx, t, timeout(request.Params.Arguments, bs.config.CSSTimeout), chromedp.SetValue(sel, value, append(opts, chromedp.NodeVisible)...))
 	if err != nil {
 		return nil, fmt.Errorf("failed to select %s with value %s: %v", selector, value, err)
 	}
@@ -293,12 +273,16 @@ func (bs *BrowserService) handleSelect(ctx context.Context, request mcp.CallTool
 }
 
 func (bs *BrowserService) handleHover(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	selector, ok := request.Params.Arguments["selector"].(string)
-	if !ok {
-		return nil, fmt.Errorf("selector must be a string")
+	t, err := bs.tabOf(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	sel, opts, selector, err := bs.elementSelector(ctx, t, request.Params.Arguments, timeout(request.Params.Arguments, bs.config.CSSTimeout))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
 	}
 	var res bool
-	err := chromedp.Run(bs.ctx, chromedp.Evaluate(`document.querySelector('`+selector+`').dispatchEvent(new Event('mouseover'))`, &res))
+	err = bs.run(ctx, t, timeout(request.Params.Arguments, bs.config.CSSTimeout), dispatchMouseOver(sel, opts, &res))
 	if err != nil {
 		return nil, fmt.Errorf("failed to hover over %s: %v", selector, err)
 	}
@@ -317,8 +301,12 @@ func (bs *BrowserService) handleEvaluate(ctx context.Context, request mcp.CallTo
 	if !ok {
 		return nil, fmt.Errorf("script must be a string")
 	}
+	t, err := bs.tabOf(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
 	var result interface{}
-	err := chromedp.Run(bs.ctx, chromedp.Evaluate(script, &result))
+	err = bs.run(ctx, t, timeout(request.Params.Arguments, bs.config.Timeout), chromedp.Evaluate(script, &result))
 	if err != nil {
 		return nil, fmt.Errorf("failed to evaluate %s: %v", script, err)
 	}
@@ -333,8 +321,9 @@ func (bs *BrowserService) handleEvaluate(ctx context.Context, request mcp.CallTo
 }
 
 func (bs *BrowserService) Close() error {
-	bs.cancel()
-	return nil
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	return bs.stopBrowserLocked()
 }
 
 func (bs *BrowserService) Config() string {
@@ -345,21 +334,92 @@ func (bs *BrowserService) Name() string {
 	return bs.name
 }
 
-func (bs *BrowserService) initBrowser(userDataDir string) error {
-	_, err := os.Stat(userDataDir)
-	if err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to check user data directory: %v", err)
-	}
-	if err == nil {
-		err = os.RemoveAll(userDataDir)
-		if err != nil {
-			return fmt.Errorf("failed to remove user data directory: %v", err)
-		}
+func (bs *BrowserService) initBrowser() error {
+	if err := os.MkdirAll(bs.config.DataPath, 0755); err != nil {
+		return fmt.Errorf("failed to create data directory: %v", err)
 	}
-	err = os.MkdirAll(userDataDir, 0755)
+	downloads, err := newDownloads(bs.config.DownloadsDir())
 	if err != nil {
-		return fmt.Errorf("failed to create user data directory: %v", err)
+		return err
 	}
-
+	bs.downloads = downloads
+	profile, err := openProfile(bs.config.ProfilesDir(), bs.config.Profile, bs.config.Ephemeral)
+	if err != nil {
+		return err
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.resetBrowserLocked(profile)
 	return nil
 }
+
+// resetBrowserLocked stops the running browser and sets up a new one on
+// profile, which is launched on first use. bs.mu must be held.
+func (bs *BrowserService) resetBrowserLocked(profile *browserProfile) {
+	if err := bs.stopBrowserLocked(); err != nil {
+		slog.Warn("Failed to release browser profile", "profile", bs.profile.name, "error", err)
+	}
+	bs.profile = profile
+
+	opts := append(AllocatorOptions(bs.config), chromedp.UserDataDir(profile.dir))
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(bs.baseCtx, opts...)
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	bs.ctx = browserCtx
+	bs.cancel = func() {
+		cancelBrowser()
+		cancelAlloc()
+		// Chrome writes to its profile until it has exited
+		chromedp.FromContext(allocCtx).Allocator.Wait()
+	}
+	bs.started = false
+	bs.tabs = make(map[string]*tab)
+	bs.current = ""
+}
+
+// stopBrowserLocked stops Chrome, waiting for it to exit, and only then
+// releases its profile, which removes the directory of an ephemeral one
+func (bs *BrowserService) stopBrowserLocked() error {
+	if bs.cancel != nil {
+		bs.cancel()
+		bs.cancel = nil
+	}
+	if bs.profile == nil {
+		return nil
+	}
+	return bs.profile.Close()
+}
+
+// dispatchMouseOver fires a mouseover event on the first element matching sel
+func dispatchMouseOver(sel interface{}, opts []chromedp.QueryOption, res *bool) chromedp.Action {
+	var nodes []*cdp.Node
+	return chromedp.Tasks{
+		chromedp.Nodes(sel, &nodes, opts...),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			return callOnNode(ctx, nodes[0].NodeID, `function() { return this.dispatchEvent(new Event('mouseover')) }`, res)
+		}),
+	}
+}
+
+// callOnNode calls the JavaScript function fn with the node as this and
+// decodes its return value into res
+func callOnNode(ctx context.Context, id cdp.NodeID, fn string, res interface{}) error {
+	obj, err := dom.ResolveNode().WithNodeID(id).Do(ctx)
+	if err != nil {
+		return err
+	}
+	defer runtime.ReleaseObject(obj.ObjectID).Do(ctx)
+	v, exc, err := runtime.CallFunctionOn(fn).
+		WithObjectID(obj.ObjectID).
+		WithReturnByValue(true).
+		Do(ctx)
+	if err != nil {
+		return err
+	}
+	if exc != nil {
+		return exc
+	}
+	if res == nil {
+		return nil
+	}
+	return json.Unmarshal(v.Value, res)
+}
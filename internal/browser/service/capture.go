@@ -0,0 +1,596 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/log"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// maxCapturedEntries is how many console messages and how many requests
+	// a tab keeps, older ones are dropped first
+	maxCapturedEntries = 1000
+	// maxBodySize caps request and response bodies in tool results
+	maxBodySize = 64 << 10
+	// defaultCaptureLimit is how many entries a query returns by default
+	defaultCaptureLimit = 100
+)
+
+// consoleLevels orders the console levels by severity
+var consoleLevels = map[string]int{
+	"debug":   0,
+	"info":    1,
+	"warning": 2,
+	"error":   3,
+}
+
+// consoleEntry is a console message, an uncaught exception or a message the
+// browser logged itself
+type consoleEntry struct {
+	Seq    int64     `json:"seq"`
+	Time   time.Time `json:"time"`
+	Level  string    `json:"level"`
+	Source string    `json:"source"`
+	Text   string    `json:"text"`
+	URL    string    `json:"url,omitempty"`
+	Line   int64     `json:"line,omitempty"`
+}
+
+// networkEntry is a request and, once it arrived, its response. Redirects
+// end up as one entry per hop.
+type networkEntry struct {
+	// Seq changes whenever the entry does, so a since cursor also returns
+	// requests that finished after they were first seen
+	Seq        int64     `json:"seq"`
+	ID         string    `json:"id"`
+	Started    time.Time `json:"started"`
+	Method     string    `json:"method"`
+	URL        string    `json:"url"`
+	Type       string    `json:"type,omitempty"`
+	Status     int64     `json:"status,omitempty"`
+	StatusText string    `json:"status_text,omitempty"`
+	MimeType   string    `json:"mime_type,omitempty"`
+	Size       int64     `json:"size"`
+	DurationMS float64   `json:"duration_ms,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Done       bool      `json:"done"`
+	// Redirected marks a hop that ended in a redirect, the request id is
+	// shared by every hop and only the last one has a body
+	Redirected   bool   `json:"redirected,omitempty"`
+	RequestBody  string `json:"request_body,omitempty"`
+	ResponseBody string `json:"response_body,omitempty"`
+
+	requestID network.RequestID
+	request   *network.Request
+	response  *network.Response
+	// requestExtra and responseExtra carry the headers as they went over the
+	// wire, including cookies, when the network stack reported them
+	requestExtra  *network.EventRequestWillBeSentExtraInfo
+	responseExtra *network.EventResponseReceivedExtraInfo
+	// start and end are browser monotonic times
+	start, end time.Time
+}
+
+// recorder buffers the console and network activity of a tab. Its handler
+// runs on the tab's event loop and must not block.
+type recorder struct {
+	mu       sync.Mutex
+	seq      int64
+	console  []*consoleEntry
+	requests []*networkEntry
+	pending  map[network.RequestID]*networkEntry
+	// extra info may arrive before the request or response it belongs to,
+	// it waits here until then, one per redirect hop
+	requestExtras  map[network.RequestID][]*network.EventRequestWillBeSentExtraInfo
+	responseExtras map[network.RequestID][]*network.EventResponseReceivedExtraInfo
+}
+
+func newRecorder() *recorder {
+	return &recorder{
+		pending:        make(map[network.RequestID]*networkEntry),
+		requestExtras:  make(map[network.RequestID][]*network.EventRequestWillBeSentExtraInfo),
+		responseExtras: make(map[network.RequestID][]*network.EventResponseReceivedExtraInfo),
+	}
+}
+
+func (r *recorder) handle(ev any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch e := ev.(type) {
+	case *runtime.EventConsoleAPICalled:
+		r.addConsoleLocked(&consoleEntry{
+			Time:   runtimeTime(e.Timestamp),
+			Level:  consoleLevel(string(e.Type)),
+			Source: "console",
+			Text:   describeArgs(e.Args),
+		})
+	case *runtime.EventExceptionThrown:
+		d := e.ExceptionDetails
+		text := d.Text
+		if d.Exception != nil && d.Exception.Description != "" {
+			text = d.Exception.Description
+		}
+		r.addConsoleLocked(&consoleEntry{
+			Time:   runtimeTime(e.Timestamp),
+			Level:  "error",
+			Source: "exception",
+			Text:   text,
+			URL:    d.URL,
+			Line:   d.LineNumber + 1,
+		})
+	case *log.EventEntryAdded:
+		r.addConsoleLocked(&consoleEntry{
+			Time:   runtimeTime(e.Entry.Timestamp),
+			Level:  consoleLevel(string(e.Entry.Level)),
+			Source: "browser",
+			Text:   e.Entry.Text,
+			URL:    e.Entry.URL,
+			Line:   e.Entry.LineNumber,
+		})
+	case *network.EventRequestWillBeSent:
+		if n, ok := r.pending[e.RequestID]; ok && e.RedirectResponse != nil {
+			n.setResponse(e.RedirectResponse)
+			n.Redirected = true
+			r.finishLocked(n, e.Timestamp, int64(e.RedirectResponse.EncodedDataLength), "")
+		}
+		n := &networkEntry{
+			ID:        string(e.RequestID),
+			Method:    e.Request.Method,
+			URL:       e.Request.URL + e.Request.URLFragment,
+			Type:      string(e.Type),
+			requestID: e.RequestID,
+			request:   e.Request,
+			start:     monotonicTime(e.Timestamp),
+		}
+		n.Started = time.Now()
+		if e.WallTime != nil {
+			n.Started = e.WallTime.Time()
+		}
+		if extras := r.requestExtras[e.RequestID]; len(extras) > 0 {
+			n.requestExtra, r.requestExtras[e.RequestID] = extras[0], extras[1:]
+		}
+		if extras := r.responseExtras[e.RequestID]; len(extras) > 0 {
+			n.responseExtra, r.responseExtras[e.RequestID] = extras[0], extras[1:]
+		}
+		r.pending[e.RequestID] = n
+		r.addRequestLocked(n)
+	case *network.EventRequestWillBeSentExtraInfo:
+		if n, ok := r.pending[e.RequestID]; ok && n.requestExtra == nil {
+			n.requestExtra = e
+			r.touchLocked(n)
+		} else if len(r.requestExtras) < maxCapturedEntries {
+			r.requestExtras[e.RequestID] = append(r.requestExtras[e.RequestID], e)
+		}
+	case *network.EventResponseReceivedExtraInfo:
+		if n, ok := r.pending[e.RequestID]; ok && n.responseExtra == nil {
+			n.responseExtra = e
+			r.touchLocked(n)
+		} else if len(r.responseExtras) < maxCapturedEntries {
+			r.responseExtras[e.RequestID] = append(r.responseExtras[e.RequestID], e)
+		}
+	case *network.EventResponseReceived:
+		if n, ok := r.pending[e.RequestID]; ok {
+			n.setResponse(e.Response)
+			r.touchLocked(n)
+		}
+	case *network.EventLoadingFinished:
+		r.dropExtrasLocked(e.RequestID)
+		if n, ok := r.pending[e.RequestID]; ok {
+			r.finishLocked(n, e.Timestamp, int64(e.EncodedDataLength), "")
+		}
+	case *network.EventLoadingFailed:
+		r.dropExtrasLocked(e.RequestID)
+		if n, ok := r.pending[e.RequestID]; ok {
+			msg := e.ErrorText
+			if e.Canceled {
+				msg = "canceled"
+			} else if e.BlockedReason != "" {
+				msg = fmt.Sprintf("%s (blocked: %s)", msg, e.BlockedReason)
+			}
+			r.finishLocked(n, e.Timestamp, n.Size, msg)
+		}
+	}
+}
+
+// dropExtrasLocked forgets extra info that never found its request, once the
+// request is over
+func (r *recorder) dropExtrasLocked(id network.RequestID) {
+	delete(r.requestExtras, id)
+	delete(r.responseExtras, id)
+}
+
+func (r *recorder) addConsoleLocked(c *consoleEntry) {
+	r.seq++
+	c.Seq = r.seq
+	r.console = append(r.console, c)
+	if len(r.console) > maxCapturedEntries {
+		r.console = r.console[len(r.console)-maxCapturedEntries:]
+	}
+}
+
+func (r *recorder) addRequestLocked(n *networkEntry) {
+	r.touchLocked(n)
+	r.requests = append(r.requests, n)
+	if len(r.requests) > maxCapturedEntries {
+		for _, old := range r.requests[:len(r.requests)-maxCapturedEntries] {
+			if r.pending[old.requestID] == old {
+				delete(r.pending, old.requestID)
+			}
+		}
+		r.requests = r.requests[len(r.requests)-maxCapturedEntries:]
+	}
+}
+
+func (r *recorder) touchLocked(n *networkEntry) {
+	r.seq++
+	n.Seq = r.seq
+}
+
+func (r *recorder) finishLocked(n *networkEntry, ts *cdp.MonotonicTime, size int64, errText string) {
+	n.Done = true
+	n.Size = size
+	n.Error = errText
+	n.end = monotonicTime(ts)
+	if !n.start.IsZero() && !n.end.IsZero() {
+		n.DurationMS = float64(n.end.Sub(n.start).Microseconds()) / 1000
+	}
+	if r.pending[n.requestID] == n {
+		delete(r.pending, n.requestID)
+	}
+	r.touchLocked(n)
+}
+
+func (n *networkEntry) setResponse(res *network.Response) {
+	n.response = res
+	n.Status = res.Status
+	n.StatusText = res.StatusText
+	n.MimeType = res.MimeType
+}
+
+// consoleQuery filters the console buffer
+type consoleQuery struct {
+	level    string
+	contains string
+	since    int64
+	limit    int
+}
+
+// consoleMessages returns copies of the console entries matching q, oldest
+// first, and whether more matched than the limit allowed
+func (r *recorder) consoleMessages(q consoleQuery) ([]consoleEntry, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []consoleEntry
+	for _, c := range r.console {
+		if c.Seq <= q.since || consoleLevels[c.Level] < consoleLevels[q.level] {
+			continue
+		}
+		if q.contains != "" && !strings.Contains(strings.ToLower(c.Text), strings.ToLower(q.contains)) {
+			continue
+		}
+		if len(out) == q.limit {
+			return out, true
+		}
+		out = append(out, *c)
+	}
+	return out, false
+}
+
+// networkQuery filters the request buffer
+type networkQuery struct {
+	url    *regexp.Regexp
+	method string
+	status string
+	typ    string
+	since  int64
+	limit  int
+}
+
+func (q networkQuery) match(n *networkEntry) bool {
+	if n.Seq <= q.since {
+		return false
+	}
+	if q.url != nil && !q.url.MatchString(n.URL) {
+		return false
+	}
+	if q.method != "" && !strings.EqualFold(q.method, n.Method) {
+		return false
+	}
+	if q.typ != "" && !strings.EqualFold(q.typ, n.Type) {
+		return false
+	}
+	return q.status == "" || matchStatus(q.status, n)
+}
+
+// matchStatus matches a status filter: a code like 404, a class like 4xx,
+// failed or pending
+func matchStatus(filter string, n *networkEntry) bool {
+	switch f := strings.ToLower(filter); {
+	case f == "failed":
+		return n.Error != ""
+	case f == "pending":
+		return !n.Done
+	case len(f) == 3 && strings.HasSuffix(f, "xx"):
+		return n.Status/100 == int64(f[0]-'0')
+	default:
+		code, err := strconv.ParseInt(f, 10, 64)
+		return err == nil && n.Status == code
+	}
+}
+
+// networkRequests returns copies of the requests matching q in the order
+// they last changed, and whether more matched than the limit allowed
+func (r *recorder) networkRequests(q networkQuery) ([]networkEntry, bool) {
+	r.mu.Lock()
+	var out []networkEntry
+	for _, n := range r.requests {
+		if q.match(n) {
+			out = append(out, *n)
+		}
+	}
+	r.mu.Unlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Seq < out[j].Seq })
+	if len(out) > q.limit {
+		return out[:q.limit], true
+	}
+	return out, false
+}
+
+// consoleLevel maps console API types and browser log levels onto
+// consoleLevels
+func consoleLevel(t string) string {
+	switch t {
+	case "error", "assert":
+		return "error"
+	case "warning":
+		return "warning"
+	case "debug", "verbose":
+		return "debug"
+	default:
+		return "info"
+	}
+}
+
+// describeArgs formats console arguments the way the console shows them
+func describeArgs(args []*runtime.RemoteObject) string {
+	parts := make([]string, 0, len(args))
+	for _, a := range args {
+		var s string
+		switch {
+		case a.Type == runtime.TypeString:
+			_ = json.Unmarshal(a.Value, &s)
+		case a.UnserializableValue != "":
+			s = string(a.UnserializableValue)
+		case a.Description != "":
+			s = a.Description
+		case len(a.Value) > 0:
+			s = string(a.Value)
+		default:
+			s = string(a.Type)
+		}
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " ")
+}
+
+func runtimeTime(ts *runtime.Timestamp) time.Time {
+	if ts == nil {
+		return time.Now()
+	}
+	return ts.Time()
+}
+
+func monotonicTime(ts *cdp.MonotonicTime) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.Time()
+}
+
+// sinceArg is the cursor argument of the capture tools
+func sinceArg() mcp.ToolOption {
+	return mcp.WithNumber("since",
+		mcp.Description("Only return entries after this cursor, use the cursor of the previous result to get what is new"),
+	)
+}
+
+func limitArg() mcp.ToolOption {
+	return mcp.WithNumber("limit",
+		mcp.Description(fmt.Sprintf("The maximum number of entries to return, default: %d", defaultCaptureLimit)),
+	)
+}
+
+func (bs *BrowserService) registerCaptureTools() {
+	bs.AddTool(mcp.NewTool(
+		"browser_console_logs",
+		mcp.WithDescription("Return the console messages, uncaught exceptions and browser log entries of a tab"),
+		mcp.WithString("level",
+			mcp.Description("The minimum level to return"),
+			mcp.DefaultString("debug"),
+			mcp.Enum("debug", "info", "warning", "error"),
+		),
+		mcp.WithString("contains",
+			mcp.Description("Only return messages containing this text, ignoring case"),
+		),
+		sinceArg(),
+		limitArg(),
+		tabArg(),
+	), bs.handleConsoleLogs)
+
+	bs.AddTool(mcp.NewTool(
+		"browser_network_requests",
+		mcp.WithDescription("Return the network requests of a tab with method, status, timing and size"),
+		mcp.WithString("url",
+			mcp.Description("Regular expression the request URL has to match"),
+		),
+		mcp.WithString("method",
+			mcp.Description("Only return requests with this HTTP method"),
+		),
+		mcp.WithString("status",
+			mcp.Description("Only return requests with this status: a code like 404, a class like 4xx, failed or pending"),
+		),
+		mcp.WithString("type",
+			mcp.Description("Only return requests of this resource type, e.g. Document, XHR, Fetch, Script, Image"),
+		),
+		mcp.WithBoolean("include_bodies",
+			mcp.Description(fmt.Sprintf("Include request and response bodies, cut at %d KB", maxBodySize>>10)),
+			mcp.DefaultBool(false),
+		),
+		sinceArg(),
+		limitArg(),
+		tabArg(),
+	), bs.handleNetworkRequests)
+}
+
+// captureArgs returns the since and limit arguments of a request
+func captureArgs(args map[string]interface{}) (int64, int) {
+	since, _ := args["since"].(float64)
+	limit := defaultCaptureLimit
+	if v, ok := args["limit"].(float64); ok && v > 0 {
+		limit = int(v)
+	}
+	return int64(since), limit
+}
+
+// captureResult is the JSON result of the capture tools. Cursor is the since
+// value to pass to get the entries after these.
+type captureResult struct {
+	Cursor  int64       `json:"cursor"`
+	More    bool        `json:"more,omitempty"`
+	Entries interface{} `json:"entries"`
+}
+
+func jsonResult(v interface{}) (*mcp.CallToolResult, error) {
+	payload, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(string(payload)), nil
+}
+
+func (bs *BrowserService) handleConsoleLogs(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	q := consoleQuery{}
+	q.since, q.limit = captureArgs(args)
+	q.level, _ = args["level"].(string)
+	if _, ok := consoleLevels[q.level]; q.level != "" && !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("unknown level: %s", q.level)), nil
+	}
+	q.contains, _ = args["contains"].(string)
+
+	t, err := bs.tabOf(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	entries, more := t.rec.consoleMessages(q)
+	res := captureResult{Cursor: q.since, More: more, Entries: []consoleEntry{}}
+	if len(entries) > 0 {
+		res.Cursor = entries[len(entries)-1].Seq
+		res.Entries = entries
+	}
+	return jsonResult(res)
+}
+
+func (bs *BrowserService) handleNetworkRequests(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	q := networkQuery{}
+	q.since, q.limit = captureArgs(args)
+	if pattern, _ := args["url"].(string); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("invalid url pattern: %v", err)), nil
+		}
+		q.url = re
+	}
+	q.method, _ = args["method"].(string)
+	q.status, _ = args["status"].(string)
+	q.typ, _ = args["type"].(string)
+
+	t, err := bs.tabOf(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	entries, more := t.rec.networkRequests(q)
+	if include, _ := args["include_bodies"].(bool); include {
+		for i := range entries {
+			bs.fetchBodies(ctx, t, &entries[i])
+		}
+	}
+	res := captureResult{Cursor: q.since, More: more, Entries: []networkEntry{}}
+	if len(entries) > 0 {
+		res.Cursor = entries[len(entries)-1].Seq
+		res.Entries = entries
+	}
+	return jsonResult(res)
+}
+
+// fetchBodies fills in the bodies of a request the browser still has. Bodies
+// it no longer has, e.g. after the page navigated away, are noted instead.
+func (bs *BrowserService) fetchBodies(ctx context.Context, t *tab, n *networkEntry) {
+	if n.hasRequestBody() {
+		data, err := bs.requestBody(ctx, t, n)
+		n.RequestBody = bodyText([]byte(data), err)
+	}
+	if n.hasResponseBody() {
+		body, err := bs.responseBody(ctx, t, n)
+		n.ResponseBody = bodyText(body, err)
+	}
+}
+
+func (n *networkEntry) hasRequestBody() bool {
+	return n.request != nil && n.request.HasPostData
+}
+
+func (n *networkEntry) hasResponseBody() bool {
+	return n.Done && !n.Redirected && n.Error == "" && n.response != nil
+}
+
+func (bs *BrowserService) requestBody(ctx context.Context, t *tab, n *networkEntry) (string, error) {
+	var data string
+	err := bs.run(ctx, t, time.Duration(bs.config.Timeout)*time.Second, chromedp.ActionFunc(func(ctx context.Context) (err error) {
+		data, err = network.GetRequestPostData(n.requestID).Do(ctx)
+		return err
+	}))
+	return data, err
+}
+
+func (bs *BrowserService) responseBody(ctx context.Context, t *tab, n *networkEntry) ([]byte, error) {
+	var body []byte
+	err := bs.run(ctx, t, time.Duration(bs.config.Timeout)*time.Second, chromedp.ActionFunc(func(ctx context.Context) (err error) {
+		body, err = network.GetResponseBody(n.requestID).Do(ctx)
+		return err
+	}))
+	return body, err
+}
+
+// bodyText formats a body for a tool result
+func bodyText(body []byte, err error) string {
+	switch {
+	case err != nil:
+		return fmt.Sprintf("(unavailable: %v)", err)
+	case !utf8.Valid(body):
+		return fmt.Sprintf("(binary, %d bytes)", len(body))
+	case len(body) > maxBodySize:
+		cut := maxBodySize
+		for cut > 0 && !utf8.RuneStart(body[cut]) {
+			cut--
+		}
+		return string(body[:cut]) + fmt.Sprintf("... (%d bytes)", len(body))
+	default:
+		return string(body)
+	}
+}
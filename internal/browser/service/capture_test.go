@@ -0,0 +1,139 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/runtime"
+)
+
+func monotonic(seconds float64) *cdp.MonotonicTime {
+	t := cdp.MonotonicTime(cdp.MonotonicTimeEpoch.Add(time.Duration(seconds * float64(time.Second))))
+	return &t
+}
+
+func TestRecorderConsole(t *testing.T) {
+	r := newRecorder()
+	r.handle(&runtime.EventConsoleAPICalled{
+		Type: runtime.APITypeLog,
+		Args: []*runtime.RemoteObject{
+			{Type: runtime.TypeString, Value: []byte(`"count:"`)},
+			{Type: runtime.TypeNumber, Value: []byte(`3`)},
+		},
+	})
+	r.handle(&runtime.EventConsoleAPICalled{
+		Type: runtime.APITypeWarning,
+		Args: []*runtime.RemoteObject{{Type: runtime.TypeString, Value: []byte(`"Deprecated API"`)}},
+	})
+	r.handle(&runtime.EventExceptionThrown{ExceptionDetails: &runtime.ExceptionDetails{
+		Text:       "Uncaught",
+		LineNumber: 9,
+		URL:        "https://example.com/app.js",
+		Exception:  &runtime.RemoteObject{Type: runtime.TypeObject, Description: "TypeError: x is undefined"},
+	}})
+
+	all, more := r.consoleMessages(consoleQuery{limit: 10})
+	if len(all) != 3 || more {
+		t.Fatalf("expected 3 messages, got %d (more: %v)", len(all), more)
+	}
+	if all[0].Text != "count: 3" || all[0].Level != "info" {
+		t.Errorf("unexpected message: %+v", all[0])
+	}
+	if all[2].Source != "exception" || all[2].Text != "TypeError: x is undefined" || all[2].Line != 10 {
+		t.Errorf("unexpected exception: %+v", all[2])
+	}
+
+	warnings, _ := r.consoleMessages(consoleQuery{level: "warning", limit: 10})
+	if len(warnings) != 2 {
+		t.Errorf("expected 2 warnings and errors, got %d", len(warnings))
+	}
+	since, more := r.consoleMessages(consoleQuery{since: all[0].Seq, limit: 1})
+	if len(since) != 1 || since[0].Seq != all[1].Seq || !more {
+		t.Errorf("unexpected page after the cursor: %+v (more: %v)", since, more)
+	}
+	found, _ := r.consoleMessages(consoleQuery{contains: "DEPRECATED", limit: 10})
+	if len(found) != 1 {
+		t.Errorf("expected 1 message containing the text, got %d", len(found))
+	}
+}
+
+func TestRecorderNetwork(t *testing.T) {
+	r := newRecorder()
+	r.handle(&network.EventRequestWillBeSent{
+		RequestID: "1",
+		Request:   &network.Request{URL: "http://example.com/", Method: "GET"},
+		Type:      network.ResourceTypeDocument,
+		Timestamp: monotonic(1),
+	})
+	r.handle(&network.EventRequestWillBeSent{
+		RequestID:        "1",
+		Request:          &network.Request{URL: "https://example.com/", Method: "GET"},
+		RedirectResponse: &network.Response{Status: 301, EncodedDataLength: 120},
+		Type:             network.ResourceTypeDocument,
+		Timestamp:        monotonic(1.1),
+	})
+	r.handle(&network.EventRequestWillBeSent{
+		RequestID: "2",
+		Request:   &network.Request{URL: "https://example.com/api", Method: "POST"},
+		Type:      network.ResourceTypeFetch,
+		Timestamp: monotonic(1.2),
+	})
+	r.handle(&network.EventResponseReceived{RequestID: "1", Response: &network.Response{Status: 200, MimeType: "text/html"}})
+	r.handle(&network.EventLoadingFinished{RequestID: "1", Timestamp: monotonic(1.35), EncodedDataLength: 2048})
+	first, _ := r.networkRequests(networkQuery{limit: 10})
+	r.handle(&network.EventLoadingFailed{RequestID: "2", Timestamp: monotonic(1.5), ErrorText: "net::ERR_CONNECTION_REFUSED"})
+
+	all, _ := r.networkRequests(networkQuery{limit: 10})
+	if len(all) != 3 {
+		t.Fatalf("expected 3 requests, got %d", len(all))
+	}
+	// Ordered by their last change, the failed request comes last.
+	if all[0].Status != 301 || all[0].Size != 120 || !all[0].Done || all[0].DurationMS != 100 {
+		t.Errorf("unexpected redirect: %+v", all[0])
+	}
+	// Only the last hop has a body to fetch, they all share the request id.
+	if !all[0].Redirected || all[0].hasResponseBody() || !all[1].hasResponseBody() {
+		t.Errorf("expected only the final hop to have a response body: %+v %+v", all[0], all[1])
+	}
+	if all[1].URL != "https://example.com/" || all[1].Status != 200 || all[1].Size != 2048 || all[1].DurationMS != 250 {
+		t.Errorf("unexpected document: %+v", all[1])
+	}
+	if all[2].Error == "" || !all[2].Done {
+		t.Errorf("unexpected failed request: %+v", all[2])
+	}
+
+	tests := []struct {
+		q    networkQuery
+		want int
+	}{
+		{networkQuery{status: "3xx"}, 1},
+		{networkQuery{status: "200"}, 1},
+		{networkQuery{status: "failed"}, 1},
+		{networkQuery{status: "pending"}, 0},
+		{networkQuery{method: "post"}, 1},
+		{networkQuery{typ: "document"}, 2},
+		{networkQuery{url: regexp.MustCompile(`/api$`)}, 1},
+		{networkQuery{since: first[len(first)-1].Seq}, 1},
+	}
+	for _, tt := range tests {
+		tt.q.limit = 10
+		got, _ := r.networkRequests(tt.q)
+		if len(got) != tt.want {
+			t.Errorf("query %+v returned %d requests, want %d", tt.q, len(got), tt.want)
+		}
+	}
+}
+
+func TestBodyText(t *testing.T) {
+	if got := bodyText([]byte{0xff, 0xfe}, nil); got != "(binary, 2 bytes)" {
+		t.Errorf("unexpected binary body: %q", got)
+	}
+	long := strings.Repeat("a", maxBodySize+10)
+	if got := bodyText([]byte(long), nil); !strings.HasSuffix(got, "... (65546 bytes)") {
+		t.Errorf("expected the body to be cut, got %d bytes", len(got))
+	}
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/dyike/MonoMCPHub/internal/browser/config"
+	"github.com/dyike/MonoMCPHub/pkg/httpclient"
+)
+
+// AllocatorOptions returns the Chrome launch options derived from the browser config
+func AllocatorOptions(bconf *config.BrowserConfig) []chromedp.ExecAllocatorOption {
+	// bconf.Proxy overrides the process-wide proxy the HTTP clients use
+	return ChromeOptions(bconf, httpclient.Resolve(&httpclient.Config{Proxy: bconf.Proxy}))
+}
+
+// ChromeOptions returns the Chrome launch options of bconf with the proxy,
+// no-proxy and CA settings of hc, bconf.Proxy is not used
+func ChromeOptions(bconf *config.BrowserConfig, hc *httpclient.Config) []chromedp.ExecAllocatorOption {
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.UserAgent(bconf.UserAgent),
+		chromedp.Flag("headless", bconf.Headless),
+		chromedp.Flag("lang", bconf.DefaultLanguage),
+		chromedp.WindowSize(1312, 848),
+	)
+
+	server, bypass, err := hc.ChromeFlags()
+	if err != nil {
+		slog.Warn("Ignoring invalid browser proxy", "error", err)
+	} else if server != "" {
+		opts = append(opts, chromedp.ProxyServer(server))
+		if bypass != "" {
+			opts = append(opts, chromedp.Flag("proxy-bypass-list", bypass))
+		}
+	}
+	spki, err := hc.ChromeSPKIList()
+	if err != nil {
+		slog.Warn("Ignoring invalid CA bundle", "error", err)
+	} else if spki != "" {
+		opts = append(opts, chromedp.Flag("ignore-certificate-errors-spki-list", spki))
+	}
+	return opts
+}
+
+// NavigateNetworkIdle navigates to urlstr and waits until the main frame has
+// had no network activity for a short while, which is when most client-side
+// rendered pages have finished loading their data.
+func NavigateNetworkIdle(urlstr string) chromedp.Action {
+	return NavigateUntil(urlstr, LifecycleNetworkIdle)
+}
+
+// Page lifecycle events NavigateUntil can wait for
+const (
+	LifecycleDOMContentLoaded = "DOMContentLoaded"
+	LifecycleNetworkIdle      = "networkIdle"
+)
+
+// NavigateUntil navigates to urlstr and waits until the new document of the
+// main frame has fired the lifecycle event. Events are matched by the loader
+// of that document, so ones still coming from the previous page don't count.
+func NavigateUntil(urlstr, event string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		w := newLifecycleWaiter(event)
+		lctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		chromedp.ListenTarget(lctx, w.handle)
+
+		frameID, loaderID, errorText, err := page.Navigate(urlstr).Do(ctx)
+		if err != nil {
+			return err
+		}
+		if errorText != "" {
+			return fmt.Errorf("page load error %s", errorText)
+		}
+		if loaderID == "" {
+			// Same-document navigations, e.g. to an anchor, load nothing.
+			return nil
+		}
+		return w.wait(ctx, frameID, loaderID)
+	})
+}
+
+// lifecycleDocument is a document loaded into a frame
+type lifecycleDocument struct {
+	frame  cdp.FrameID
+	loader cdp.LoaderID
+}
+
+// lifecycleWaiter records which documents fired a lifecycle event. The event
+// may fire before page.Navigate returns the loader, so all of them are kept.
+type lifecycleWaiter struct {
+	event  string
+	mu     sync.Mutex
+	fired  map[lifecycleDocument]bool
+	notify chan struct{}
+}
+
+func newLifecycleWaiter(event string) *lifecycleWaiter {
+	return &lifecycleWaiter{
+		event:  event,
+		fired:  make(map[lifecycleDocument]bool),
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// handle runs on the tab's event loop and must not block
+func (w *lifecycleWaiter) handle(ev any) {
+	e, ok := ev.(*page.EventLifecycleEvent)
+	if !ok || e.Name != w.event {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.fired[lifecycleDocument{e.FrameID, e.LoaderID}] = true
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+}
+
+// wait returns once the document of loader in frame has fired the event
+func (w *lifecycleWaiter) wait(ctx context.Context, frame cdp.FrameID, loader cdp.LoaderID) error {
+	for {
+		w.mu.Lock()
+		done := w.fired[lifecycleDocument{frame, loader}]
+		w.mu.Unlock()
+		if done {
+			return nil
+		}
+		select {
+		case <-w.notify:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+)
+
+func TestLifecycleWaiter(t *testing.T) {
+	w := newLifecycleWaiter(LifecycleNetworkIdle)
+	// The previous document going idle, or another event of the new one,
+	// does not end the wait.
+	w.handle(&page.EventLifecycleEvent{FrameID: "main", LoaderID: "old", Name: LifecycleNetworkIdle})
+	w.handle(&page.EventLifecycleEvent{FrameID: "main", LoaderID: "new", Name: LifecycleDOMContentLoaded})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := w.wait(ctx, "main", "new"); err == nil {
+		t.Fatalf("expected events of other documents to be ignored")
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- w.wait(context.Background(), "main", "new") }()
+	w.handle(&page.EventLifecycleEvent{FrameID: "main", LoaderID: "new", Name: LifecycleNetworkIdle})
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected the new document going idle to end the wait")
+	}
+
+	// An event that fired before the loader was known counts as well.
+	if err := w.wait(context.Background(), "main", "new"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
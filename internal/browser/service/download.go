@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxDownloads is how many downloads are listed, older ones are forgotten.
+// Their files stay in the downloads directory.
+const maxDownloads = 1000
+
+// Download states
+const (
+	downloadInProgress = "in_progress"
+	downloadCompleted  = "completed"
+	downloadCanceled   = "canceled"
+	downloadFailed     = "failed"
+)
+
+// download is a file a page downloaded. Chrome saves it under its GUID, it is
+// renamed to the name the page suggested once it is complete.
+type download struct {
+	Seq      int64      `json:"seq"`
+	URL      string     `json:"url"`
+	Name     string     `json:"name"`
+	State    string     `json:"state"`
+	Path     string     `json:"path,omitempty"`
+	Size     int64      `json:"size"`
+	SHA256   string     `json:"sha256,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+
+	guid string
+}
+
+func (d *download) done() bool {
+	return d.State != downloadInProgress
+}
+
+// downloads tracks the downloads of the browser in dir
+type downloads struct {
+	dir string
+
+	mu    sync.Mutex
+	seq   int64
+	list  []*download
+	guids map[string]*download
+	// changed is closed and replaced whenever a download changes
+	changed chan struct{}
+}
+
+func newDownloads(dir string) (*downloads, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create downloads directory: %v", err)
+	}
+	return &downloads{
+		dir:     dir,
+		guids:   make(map[string]*download),
+		changed: make(chan struct{}),
+	}, nil
+}
+
+// behavior makes the browser save downloads in the directory and report them
+func (ds *downloads) behavior() *browser.SetDownloadBehaviorParams {
+	return browser.SetDownloadBehavior(browser.SetDownloadBehaviorBehaviorAllowAndName).
+		WithDownloadPath(ds.dir).
+		WithEventsEnabled(true)
+}
+
+// handle runs on the browser's event loop and must not block
+func (ds *downloads) handle(ev any) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	switch e := ev.(type) {
+	case *browser.EventDownloadWillBegin:
+		ds.seq++
+		d := &download{
+			Seq:     ds.seq,
+			URL:     e.URL,
+			Name:    e.SuggestedFilename,
+			State:   downloadInProgress,
+			Started: time.Now(),
+			guid:    e.GUID,
+		}
+		ds.list = append(ds.list, d)
+		if len(ds.list) > maxDownloads {
+			ds.list = ds.list[len(ds.list)-maxDownloads:]
+		}
+		ds.guids[e.GUID] = d
+	case *browser.EventDownloadProgress:
+		d, ok := ds.guids[e.GUID]
+		if !ok || d.done() {
+			return
+		}
+		d.Size = int64(e.ReceivedBytes)
+		switch e.State {
+		case browser.DownloadProgressStateCompleted:
+			delete(ds.guids, e.GUID)
+			go ds.complete(d)
+			return
+		case browser.DownloadProgressStateCanceled:
+			delete(ds.guids, e.GUID)
+			ds.finishLocked(d, downloadCanceled)
+		}
+	default:
+		return
+	}
+	ds.notifyLocked()
+}
+
+// complete moves a finished download to its final name and hashes it
+func (ds *downloads) complete(d *download) {
+	ds.mu.Lock()
+	name := d.Name
+	ds.mu.Unlock()
+
+	path, size, sum, err := ds.store(filepath.Join(ds.dir, d.guid), name)
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	if err != nil {
+		slog.Warn("Failed to store download", "url", d.URL, "error", err)
+		d.Error = err.Error()
+		ds.finishLocked(d, downloadFailed)
+	} else {
+		d.Path, d.Size, d.SHA256 = path, size, sum
+		ds.finishLocked(d, downloadCompleted)
+	}
+	ds.notifyLocked()
+}
+
+func (ds *downloads) store(src, name string) (string, int64, string, error) {
+	path, err := reservePath(ds.dir, name)
+	if err != nil {
+		return "", 0, "", err
+	}
+	if err := os.Rename(src, path); err != nil {
+		os.Remove(path)
+		return "", 0, "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, "", err
+	}
+	return path, size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (ds *downloads) finishLocked(d *download, state string) {
+	now := time.Now()
+	d.State = state
+	d.Finished = &now
+}
+
+func (ds *downloads) notifyLocked() {
+	close(ds.changed)
+	ds.changed = make(chan struct{})
+}
+
+// since returns copies of the downloads after the cursor since, whether all
+// of them are done, and a channel closed on the next change
+func (ds *downloads) since(since int64) ([]download, bool, <-chan struct{}) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+	out := []download{}
+	done := true
+	for _, d := range ds.list {
+		if d.Seq > since {
+			out = append(out, *d)
+			done = done && d.done()
+		}
+	}
+	return out, done, ds.changed
+}
+
+// wait waits until there is at least one download after the cursor since and
+// all of them are done
+func (ds *downloads) wait(ctx context.Context, since int64) ([]download, error) {
+	for {
+		list, done, changed := ds.since(since)
+		if len(list) > 0 && done {
+			return list, nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return list, ctx.Err()
+		}
+	}
+}
+
+// reservePath creates an empty file for name in dir and returns its path,
+// adding a counter before the extension while the name is taken. Creating
+// the file claims the name, so concurrent downloads never get the same one.
+func reservePath(dir, name string) (string, error) {
+	name = filepath.Base(name)
+	if name == "" || name == "." || name == ".." || name == string(filepath.Separator) {
+		name = "download"
+	}
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	path := filepath.Join(dir, name)
+	for i := 1; ; i++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			return path, f.Close()
+		}
+		if !os.IsExist(err) {
+			return "", err
+		}
+		path = filepath.Join(dir, fmt.Sprintf("%s (%d)%s", stem, i, ext))
+	}
+}
+
+func (bs *BrowserService) registerDownloadTool() {
+	bs.AddTool(mcp.NewTool(
+		"browser_downloads",
+		mcp.WithDescription(fmt.Sprintf("List the files pages downloaded into %s with their paths, sizes and SHA-256 hashes, optionally waiting for downloads to complete", bs.downloads.dir)),
+		sinceArg(),
+		mcp.WithBoolean("wait",
+			mcp.Description("Wait until there is a download after the cursor and all of them are done"),
+			mcp.DefaultBool(false),
+		),
+		timeoutArg(bs.config.Timeout),
+	), bs.handleDownloads)
+}
+
+func (bs *BrowserService) handleDownloads(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	cursor, _ := args["since"].(float64)
+	since := int64(cursor)
+
+	var list []download
+	if wait, _ := args["wait"].(bool); wait {
+		d := timeout(args, bs.config.Timeout)
+		waitCtx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		var err error
+		if list, err = bs.downloads.wait(waitCtx, since); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Timed out after %v waiting for downloads, %d started", d, len(list))), nil
+		}
+	} else {
+		list, _, _ = bs.downloads.since(since)
+	}
+
+	res := struct {
+		Cursor    int64      `json:"cursor"`
+		Downloads []download `json:"downloads"`
+	}{Cursor: since, Downloads: list}
+	if len(list) > 0 {
+		res.Cursor = list[len(list)-1].Seq
+	}
+	return jsonResult(res)
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/browser"
+)
+
+func TestReservePath(t *testing.T) {
+	dir := t.TempDir()
+	reserve := func(name string) string {
+		t.Helper()
+		path, err := reservePath(dir, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	if got := reserve("../report.pdf"); got != filepath.Join(dir, "report.pdf") {
+		t.Errorf("unexpected path: %s", got)
+	}
+	if got := reserve("report.pdf"); got != filepath.Join(dir, "report (1).pdf") {
+		t.Errorf("unexpected path for a taken name: %s", got)
+	}
+	if got := reserve(""); got != filepath.Join(dir, "download") {
+		t.Errorf("unexpected path for an empty name: %s", got)
+	}
+}
+
+func TestDownloads(t *testing.T) {
+	ds, err := newDownloads(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ds.handle(&browser.EventDownloadWillBegin{GUID: "g1", URL: "https://example.com/a.txt", SuggestedFilename: "a.txt"})
+	ds.handle(&browser.EventDownloadWillBegin{GUID: "g2", URL: "https://example.com/b.zip", SuggestedFilename: "b.zip"})
+	ds.handle(&browser.EventDownloadProgress{GUID: "g2", ReceivedBytes: 10, State: browser.DownloadProgressStateCanceled})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := ds.wait(ctx, 0); err == nil {
+		t.Fatal("expected waiting to time out while a download is in progress")
+	}
+
+	if err := os.WriteFile(filepath.Join(ds.dir, "g1"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ds.handle(&browser.EventDownloadProgress{GUID: "g1", ReceivedBytes: 5, TotalBytes: 5, State: browser.DownloadProgressStateCompleted})
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	list, err := ds.wait(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].State != downloadCompleted || list[1].State != downloadCanceled {
+		t.Fatalf("unexpected downloads: %+v", list)
+	}
+	a := list[0]
+	if a.Path != filepath.Join(ds.dir, "a.txt") || a.Size != 5 ||
+		a.SHA256 != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("unexpected download: %+v", a)
+	}
+
+	if list, _, _ := ds.since(list[1].Seq); len(list) != 0 {
+		t.Errorf("expected nothing after the cursor, got %+v", list)
+	}
+
+	for i := 0; i < maxDownloads; i++ {
+		ds.handle(&browser.EventDownloadWillBegin{GUID: fmt.Sprint("more", i)})
+	}
+	if list, _, _ := ds.since(0); len(list) != maxDownloads || list[0].Seq != 3 {
+		t.Errorf("expected the %d newest downloads, got %d from %d", maxDownloads, len(list), list[0].Seq)
+	}
+}
+
+func TestDownloadsSameName(t *testing.T) {
+	ds, err := newDownloads(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 8
+	for i := 0; i < n; i++ {
+		guid := fmt.Sprint("g", i)
+		if err := os.WriteFile(filepath.Join(ds.dir, guid), []byte(guid), 0644); err != nil {
+			t.Fatal(err)
+		}
+		ds.handle(&browser.EventDownloadWillBegin{GUID: guid, SuggestedFilename: "same.txt"})
+	}
+	for i := 0; i < n; i++ {
+		ds.handle(&browser.EventDownloadProgress{GUID: fmt.Sprint("g", i), State: browser.DownloadProgressStateCompleted})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	list, err := ds.wait(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := make(map[string]bool)
+	for _, d := range list {
+		if d.State != downloadCompleted || paths[d.Path] {
+			t.Errorf("unexpected download: %+v", d)
+		}
+		paths[d.Path] = true
+	}
+	if len(paths) != n {
+		t.Errorf("expected %d distinct files, got %d", n, len(paths))
+	}
+}
@@ -0,0 +1,431 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/network"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// harVersion is the HAR spec version browser_har writes
+const harVersion = "1.2"
+
+// The HAR 1.2 format, see http://www.softwareishard.com/blog/har-12-spec/
+type (
+	harFile struct {
+		Log harLog `json:"log"`
+	}
+	harLog struct {
+		Version string     `json:"version"`
+		Creator harCreator `json:"creator"`
+		Entries []harEntry `json:"entries"`
+	}
+	harCreator struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	harEntry struct {
+		StartedDateTime string      `json:"startedDateTime"`
+		Time            float64     `json:"time"`
+		Request         harRequest  `json:"request"`
+		Response        harResponse `json:"response"`
+		Cache           struct{}    `json:"cache"`
+		Timings         harTimings  `json:"timings"`
+		ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+		ResourceType    string      `json:"_resourceType,omitempty"`
+		Error           string      `json:"_error,omitempty"`
+	}
+	harRequest struct {
+		Method      string         `json:"method"`
+		URL         string         `json:"url"`
+		HTTPVersion string         `json:"httpVersion"`
+		Cookies     []harCookie    `json:"cookies"`
+		Headers     []harNameValue `json:"headers"`
+		QueryString []harNameValue `json:"queryString"`
+		PostData    *harPostData   `json:"postData,omitempty"`
+		HeadersSize int64          `json:"headersSize"`
+		BodySize    int64          `json:"bodySize"`
+	}
+	harResponse struct {
+		Status      int64          `json:"status"`
+		StatusText  string         `json:"statusText"`
+		HTTPVersion string         `json:"httpVersion"`
+		Cookies     []harCookie    `json:"cookies"`
+		Headers     []harNameValue `json:"headers"`
+		Content     harContent     `json:"content"`
+		RedirectURL string         `json:"redirectURL"`
+		HeadersSize int64          `json:"headersSize"`
+		BodySize    int64          `json:"bodySize"`
+	}
+	harNameValue struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+	harCookie struct {
+		Name     string `json:"name"`
+		Value    string `json:"value"`
+		Path     string `json:"path,omitempty"`
+		Domain   string `json:"domain,omitempty"`
+		Expires  string `json:"expires,omitempty"`
+		HTTPOnly bool   `json:"httpOnly,omitempty"`
+		Secure   bool   `json:"secure,omitempty"`
+	}
+	harPostData struct {
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text"`
+	}
+	harContent struct {
+		Size     int64  `json:"size"`
+		MimeType string `json:"mimeType"`
+		Text     string `json:"text,omitempty"`
+		Encoding string `json:"encoding,omitempty"`
+		Comment  string `json:"comment,omitempty"`
+	}
+	// harTimings are in milliseconds, -1 where a phase does not apply
+	harTimings struct {
+		Blocked float64 `json:"blocked"`
+		DNS     float64 `json:"dns"`
+		Connect float64 `json:"connect"`
+		Send    float64 `json:"send"`
+		Wait    float64 `json:"wait"`
+		Receive float64 `json:"receive"`
+		SSL     float64 `json:"ssl"`
+	}
+)
+
+// harBodies are the bodies of a request as far as the browser still has
+// them, keyed by request id
+type harBodies struct {
+	request  map[string]string
+	response map[string][]byte
+	errors   map[string]error
+}
+
+func (bs *BrowserService) registerHARTool() {
+	bs.AddTool(mcp.NewTool(
+		"browser_har",
+		mcp.WithDescription("Export the recorded network activity of a tab as a HAR 1.2 file, which devtools and other HAR viewers can open"),
+		mcp.WithString("url",
+			mcp.Description("Regular expression the request URL has to match"),
+		),
+		mcp.WithBoolean("include_bodies",
+			mcp.Description("Include request and response bodies"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithBoolean("save",
+			mcp.Description("Save the HAR in the data directory and return its path instead of its content"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithString("name",
+			mcp.Description("The name of the HAR, used for the file name when it is saved"),
+		),
+		tabArg(),
+	), bs.handleHAR)
+}
+
+func (bs *BrowserService) handleHAR(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	q := networkQuery{limit: maxCapturedEntries}
+	if pattern, _ := args["url"].(string); pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("invalid url pattern: %v", err)), nil
+		}
+		q.url = re
+	}
+
+	t, err := bs.tabOf(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	entries, _ := t.rec.networkRequests(q)
+
+	var bodies *harBodies
+	if include, _ := args["include_bodies"].(bool); include {
+		bodies = &harBodies{
+			request:  make(map[string]string),
+			response: make(map[string][]byte),
+			errors:   make(map[string]error),
+		}
+		for i := range entries {
+			n := &entries[i]
+			if n.hasRequestBody() {
+				if data, err := bs.requestBody(ctx, t, n); err == nil {
+					bodies.request[n.ID] = data
+				}
+			}
+			if n.hasResponseBody() {
+				body, err := bs.responseBody(ctx, t, n)
+				if err != nil {
+					bodies.errors[n.ID] = err
+				} else {
+					bodies.response[n.ID] = body
+				}
+			}
+		}
+	}
+
+	har := buildHAR(entries, bodies)
+	payload, err := json.MarshalIndent(har, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if save, _ := args["save"].(bool); !save {
+		return mcp.NewToolResultText(string(payload)), nil
+	}
+	name, _ := args["name"].(string)
+	if name == "" {
+		name = "network"
+	}
+	path := filepath.Join(bs.config.DataPath, fmt.Sprintf("%s_%d.har", filepath.Base(name), time.Now().Unix()))
+	if err := os.WriteFile(path, payload, 0644); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to save HAR: %v", err)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Saved %d requests to %s", len(har.Log.Entries), path)), nil
+}
+
+// buildHAR turns the finished requests among entries into a HAR, ordered by
+// start time. bodies is nil when bodies are left out.
+func buildHAR(entries []networkEntry, bodies *harBodies) *harFile {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].start.Before(entries[j].start) })
+	har := &harFile{Log: harLog{
+		Version: harVersion,
+		Creator: harCreator{Name: "browser mcp server", Version: "0.0.1"},
+		Entries: []harEntry{},
+	}}
+	for i := range entries {
+		n := &entries[i]
+		if !n.Done || n.request == nil {
+			continue
+		}
+		har.Log.Entries = append(har.Log.Entries, harEntryOf(n, bodies))
+	}
+	return har
+}
+
+func harEntryOf(n *networkEntry, bodies *harBodies) harEntry {
+	res := n.response
+	if res == nil {
+		res = &network.Response{}
+	}
+	// Prefer the headers as they went over the wire, which include cookies
+	// and the like, to the ones the page asked for.
+	headers := n.request.Headers
+	if len(res.RequestHeaders) > 0 {
+		headers = res.RequestHeaders
+	}
+	if n.requestExtra != nil && len(n.requestExtra.Headers) > 0 {
+		headers = n.requestExtra.Headers
+	}
+	resHeaders := res.Headers
+	if n.responseExtra != nil && len(n.responseExtra.Headers) > 0 {
+		resHeaders = n.responseExtra.Headers
+	}
+	version := httpVersion(res.Protocol)
+
+	e := harEntry{
+		StartedDateTime: n.Started.UTC().Format("2006-01-02T15:04:05.000Z"),
+		Request: harRequest{
+			Method:      n.Method,
+			URL:         n.URL,
+			HTTPVersion: version,
+			Cookies:     harRequestCookies(headers),
+			Headers:     harHeaders(headers),
+			QueryString: harQuery(n.URL),
+			HeadersSize: -1,
+			BodySize:    0,
+		},
+		Response: harResponse{
+			Status:      n.Status,
+			StatusText:  n.StatusText,
+			HTTPVersion: version,
+			Cookies:     harResponseCookies(resHeaders),
+			Headers:     harHeaders(resHeaders),
+			Content:     harContent{Size: n.Size, MimeType: n.MimeType},
+			RedirectURL: headerValue(resHeaders, "Location"),
+			HeadersSize: -1,
+			BodySize:    n.Size,
+		},
+		Timings:         harTimingsOf(n),
+		ServerIPAddress: strings.Trim(res.RemoteIPAddress, "[]"),
+		ResourceType:    n.Type,
+		Error:           n.Error,
+	}
+	if n.Error != "" {
+		e.Response.BodySize = -1
+	}
+	e.Time = max(e.Timings.Blocked, 0) + max(e.Timings.DNS, 0) + max(e.Timings.Connect, 0) +
+		e.Timings.Send + e.Timings.Wait + e.Timings.Receive
+
+	if bodies == nil {
+		return e
+	}
+	if data, ok := bodies.request[n.ID]; ok {
+		e.Request.BodySize = int64(len(data))
+		e.Request.PostData = &harPostData{MimeType: headerValue(headers, "Content-Type"), Text: data}
+	}
+	// Bodies are keyed by request id, which every redirect hop shares with
+	// the final response, so a redirect never takes a body from the map.
+	if n.Redirected {
+		return e
+	}
+	if body, ok := bodies.response[n.ID]; ok {
+		e.Response.Content.Size = int64(len(body))
+		if utf8.Valid(body) {
+			e.Response.Content.Text = string(body)
+		} else {
+			e.Response.Content.Text = base64.StdEncoding.EncodeToString(body)
+			e.Response.Content.Encoding = "base64"
+		}
+	} else if err, ok := bodies.errors[n.ID]; ok {
+		e.Response.Content.Comment = fmt.Sprintf("body unavailable: %v", err)
+	}
+	return e
+}
+
+// harTimingsOf splits the duration of a request into the HAR phases using
+// the resource timing of its response, all of it counts as waiting when
+// there is none, e.g. for cached or failed requests
+func harTimingsOf(n *networkEntry) harTimings {
+	total := n.DurationMS
+	ht := harTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Wait: total}
+	if n.response == nil || n.response.Timing == nil {
+		return ht
+	}
+	rt := n.response.Timing
+	// The phases are relative to requestTime, which is in seconds on the
+	// same clock as the event timestamps.
+	requestTime := cdp.MonotonicTimeEpoch.Add(time.Duration(rt.RequestTime * float64(time.Second)))
+	queued := float64(requestTime.Sub(n.start).Microseconds()) / 1000
+
+	ht.Blocked = queued
+	for _, start := range []float64{rt.DNSStart, rt.ConnectStart, rt.SendStart} {
+		if start >= 0 {
+			ht.Blocked += start
+			break
+		}
+	}
+	if rt.DNSStart >= 0 {
+		ht.DNS = rt.DNSEnd - rt.DNSStart
+	}
+	if rt.ConnectStart >= 0 {
+		ht.Connect = rt.ConnectEnd - rt.ConnectStart
+	}
+	if rt.SslStart >= 0 {
+		ht.SSL = rt.SslEnd - rt.SslStart
+	}
+	ht.Send = max(rt.SendEnd-rt.SendStart, 0)
+	ht.Wait = max(rt.ReceiveHeadersEnd-rt.SendEnd, 0)
+	ht.Receive = max(total-queued-rt.ReceiveHeadersEnd, 0)
+	return ht
+}
+
+// httpVersion maps the protocol devtools reports onto an HTTP version
+func httpVersion(protocol string) string {
+	switch strings.ToLower(protocol) {
+	case "h2", "http/2.0":
+		return "HTTP/2.0"
+	case "h3", "http/3":
+		return "HTTP/3"
+	case "http/1.0":
+		return "HTTP/1.0"
+	case "":
+		return ""
+	default:
+		return "HTTP/1.1"
+	}
+}
+
+// harHeaders flattens headers sorted by name, devtools joins repeated
+// headers with newlines
+func harHeaders(h network.Headers) []harNameValue {
+	out := []harNameValue{}
+	for name, v := range h {
+		for _, value := range strings.Split(fmt.Sprint(v), "\n") {
+			out = append(out, harNameValue{Name: name, Value: value})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return strings.ToLower(out[i].Name) < strings.ToLower(out[j].Name) })
+	return out
+}
+
+// harRequestCookies parses the Cookie header of a request
+func harRequestCookies(h network.Headers) []harCookie {
+	out := []harCookie{}
+	for _, line := range strings.Split(headerValue(h, "Cookie"), "\n") {
+		cookies, err := http.ParseCookie(line)
+		if err != nil {
+			continue
+		}
+		for _, c := range cookies {
+			out = append(out, harCookie{Name: c.Name, Value: c.Value})
+		}
+	}
+	return out
+}
+
+// harResponseCookies parses the Set-Cookie headers of a response, which the
+// browser joins with newlines
+func harResponseCookies(h network.Headers) []harCookie {
+	out := []harCookie{}
+	for _, line := range strings.Split(headerValue(h, "Set-Cookie"), "\n") {
+		c, err := http.ParseSetCookie(line)
+		if err != nil {
+			continue
+		}
+		hc := harCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if !c.Expires.IsZero() {
+			hc.Expires = c.Expires.UTC().Format(time.RFC3339)
+		}
+		out = append(out, hc)
+	}
+	return out
+}
+
+func harQuery(rawURL string) []harNameValue {
+	out := []harNameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return out
+	}
+	for _, pair := range strings.Split(u.RawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		name, value, _ := strings.Cut(pair, "=")
+		name, _ = url.QueryUnescape(name)
+		value, _ = url.QueryUnescape(value)
+		out = append(out, harNameValue{Name: name, Value: value})
+	}
+	return out
+}
+
+// headerValue looks up a header ignoring case
+func headerValue(h network.Headers, name string) string {
+	for k, v := range h {
+		if strings.EqualFold(k, name) {
+			return fmt.Sprint(v)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,155 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/chromedp/cdproto/network"
+)
+
+func TestBuildHAR(t *testing.T) {
+	r := newRecorder()
+	r.handle(&network.EventRequestWillBeSent{
+		RequestID: "1",
+		Request: &network.Request{
+			URL:         "https://example.com/search?q=go+lang&page=2",
+			Method:      "POST",
+			Headers:     network.Headers{"Content-Type": "application/json"},
+			HasPostData: true,
+		},
+		Type:      network.ResourceTypeFetch,
+		Timestamp: monotonic(10),
+	})
+	r.handle(&network.EventResponseReceived{RequestID: "1", Response: &network.Response{
+		Status:     200,
+		StatusText: "OK",
+		MimeType:   "application/json",
+		Protocol:   "h2",
+		Headers:    network.Headers{"Set-Cookie": "a=1\nb=2", "Content-Type": "application/json"},
+		Timing: &network.ResourceTiming{
+			RequestTime:       10.01,
+			DNSStart:          -1,
+			DNSEnd:            -1,
+			ConnectStart:      -1,
+			ConnectEnd:        -1,
+			SslStart:          -1,
+			SslEnd:            -1,
+			SendStart:         2,
+			SendEnd:           3,
+			ReceiveHeadersEnd: 53,
+		},
+	}})
+	r.handle(&network.EventLoadingFinished{RequestID: "1", Timestamp: monotonic(10.1), EncodedDataLength: 300})
+	r.handle(&network.EventRequestWillBeSent{
+		RequestID: "2",
+		Request:   &network.Request{URL: "https://example.com/pending", Method: "GET"},
+		Timestamp: monotonic(10.05),
+	})
+	r.handle(&network.EventRequestWillBeSent{
+		RequestID: "3",
+		Request:   &network.Request{URL: "http://example.com/old", Method: "GET"},
+		Timestamp: monotonic(11),
+	})
+	r.handle(&network.EventRequestWillBeSent{
+		RequestID:        "3",
+		Request:          &network.Request{URL: "https://example.com/new", Method: "GET"},
+		RedirectResponse: &network.Response{Status: 301, Headers: network.Headers{"Location": "https://example.com/new"}},
+		Timestamp:        monotonic(11.1),
+	})
+	r.handle(&network.EventResponseReceived{RequestID: "3", Response: &network.Response{Status: 200, MimeType: "text/html"}})
+	r.handle(&network.EventLoadingFinished{RequestID: "3", Timestamp: monotonic(11.2), EncodedDataLength: 100})
+
+	entries, _ := r.networkRequests(networkQuery{limit: maxCapturedEntries})
+	har := buildHAR(entries, &harBodies{
+		request:  map[string]string{"1": `{"q":"go"}`},
+		response: map[string][]byte{"1": []byte(`{"hits":[]}`), "3": []byte("<p>new</p>")},
+	})
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 3 {
+		t.Fatalf("expected three finished entries, got %+v", har.Log)
+	}
+	// The redirect hop shares its request id with the final response, only
+	// the latter gets the body.
+	redirect, final := har.Log.Entries[1], har.Log.Entries[2]
+	if redirect.Response.Status != 301 || redirect.Response.Content.Text != "" || redirect.Response.RedirectURL != "https://example.com/new" {
+		t.Errorf("unexpected redirect: %+v", redirect.Response)
+	}
+	if final.Request.URL != "https://example.com/new" || final.Response.Content.Text != "<p>new</p>" {
+		t.Errorf("unexpected final response: %+v", final)
+	}
+	e := har.Log.Entries[0]
+	if e.Request.HTTPVersion != "HTTP/2.0" || len(e.Request.QueryString) != 2 || e.Request.QueryString[0].Value != "go lang" {
+		t.Errorf("unexpected request: %+v", e.Request)
+	}
+	if e.Request.PostData == nil || e.Request.PostData.MimeType != "application/json" {
+		t.Errorf("unexpected post data: %+v", e.Request.PostData)
+	}
+	if len(e.Response.Headers) != 3 || e.Response.Headers[0].Name != "Content-Type" {
+		t.Errorf("unexpected response headers: %+v", e.Response.Headers)
+	}
+	if e.Response.Content.Text != `{"hits":[]}` || e.Response.BodySize != 300 {
+		t.Errorf("unexpected content: %+v", e.Response)
+	}
+	want := harTimings{Blocked: 12, DNS: -1, Connect: -1, SSL: -1, Send: 1, Wait: 50, Receive: 37}
+	if e.Timings != want {
+		t.Errorf("unexpected timings: %+v, want %+v", e.Timings, want)
+	}
+	if e.Time != 100 {
+		t.Errorf("expected a total of 100ms, got %v", e.Time)
+	}
+}
+
+func TestHARExtraInfo(t *testing.T) {
+	r := newRecorder()
+	// Extra info may come before the request it belongs to.
+	r.handle(&network.EventRequestWillBeSentExtraInfo{
+		RequestID: "1",
+		Headers:   network.Headers{"Cookie": "sid=abc; theme=dark", "User-Agent": "test"},
+	})
+	r.handle(&network.EventRequestWillBeSent{
+		RequestID: "1",
+		Request:   &network.Request{URL: "https://example.com/login", Method: "GET", Headers: network.Headers{"User-Agent": "test"}},
+		Timestamp: monotonic(10),
+	})
+	r.handle(&network.EventResponseReceivedExtraInfo{
+		RequestID:  "1",
+		StatusCode: 302,
+		Headers:    network.Headers{"Location": "/home", "Set-Cookie": "sid=def; Path=/; HttpOnly; Secure\ntheme=light"},
+	})
+	r.handle(&network.EventRequestWillBeSentExtraInfo{
+		RequestID: "1",
+		Headers:   network.Headers{"Cookie": "sid=def; theme=light"},
+	})
+	r.handle(&network.EventRequestWillBeSent{
+		RequestID:        "1",
+		Request:          &network.Request{URL: "https://example.com/home", Method: "GET"},
+		RedirectResponse: &network.Response{Status: 302, Headers: network.Headers{"Location": "/home"}},
+		Timestamp:        monotonic(10.1),
+	})
+	r.handle(&network.EventResponseReceived{RequestID: "1", Response: &network.Response{Status: 200, Headers: network.Headers{}}})
+	r.handle(&network.EventLoadingFinished{RequestID: "1", Timestamp: monotonic(10.2)})
+
+	entries, _ := r.networkRequests(networkQuery{limit: maxCapturedEntries})
+	har := buildHAR(entries, nil)
+	if len(har.Log.Entries) != 2 {
+		t.Fatalf("expected two entries, got %+v", har.Log.Entries)
+	}
+	login, home := har.Log.Entries[0], har.Log.Entries[1]
+	if c := login.Request.Cookies; len(c) != 2 || c[0] != (harCookie{Name: "sid", Value: "abc"}) {
+		t.Errorf("unexpected request cookies: %+v", c)
+	}
+	if len(login.Request.Headers) != 2 {
+		t.Errorf("expected the headers sent over the wire, got %+v", login.Request.Headers)
+	}
+	want := harCookie{Name: "sid", Value: "def", Path: "/", HTTPOnly: true, Secure: true}
+	if c := login.Response.Cookies; len(c) != 2 || c[0] != want || c[1].Name != "theme" {
+		t.Errorf("unexpected response cookies: %+v", c)
+	}
+	if login.Response.RedirectURL != "/home" {
+		t.Errorf("unexpected redirect URL: %s", login.Response.RedirectURL)
+	}
+	if c := home.Request.Cookies; len(c) != 2 || c[0].Value != "def" {
+		t.Errorf("expected the redirect to carry the new cookies, got %+v", c)
+	}
+	if len(home.Response.Cookies) != 0 {
+		t.Errorf("unexpected response cookies: %+v", home.Response.Cookies)
+	}
+}
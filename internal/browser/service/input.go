@@ -0,0 +1,705 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/input"
+	"github.com/chromedp/chromedp"
+	"github.com/chromedp/chromedp/kb"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// defaultDragSteps is how many mouse moves a drag is split into
+	defaultDragSteps = 10
+	// maxDragSteps caps the mouse moves of a single drag
+	maxDragSteps = 100
+	// dragStartWait is how long a drag that has not started by the last
+	// mouse move is waited for
+	dragStartWait = 100 * time.Millisecond
+	// dragCleanupTimeout bounds releasing the mouse after a drag, which also
+	// happens when the drag itself timed out
+	dragCleanupTimeout = 5 * time.Second
+	// scrollSettle is how long a scroll gets to take effect before the new
+	// position is read
+	scrollSettle = 100 * time.Millisecond
+)
+
+// elementCenterJS scrolls an element into view and returns its center in
+// viewport coordinates
+const elementCenterJS = `function() {
+	this.scrollIntoView({block: "center", inline: "center"});
+	const r = this.getBoundingClientRect();
+	return {x: r.left + r.width / 2, y: r.top + r.height / 2};
+}`
+
+// scrollPositionJS returns the scroll position of the page
+const scrollPositionJS = `({x: window.scrollX, y: window.scrollY, height: document.documentElement.scrollHeight, viewport: window.innerHeight})`
+
+// modifierKeys maps modifier names, including common aliases, to their kb
+// key and input modifier bit
+var modifierKeys = map[string]struct {
+	key string
+	bit input.Modifier
+}{
+	"alt":     {kb.Alt, input.ModifierAlt},
+	"option":  {kb.Alt, input.ModifierAlt},
+	"control": {kb.Control, input.ModifierCtrl},
+	"ctrl":    {kb.Control, input.ModifierCtrl},
+	"meta":    {kb.Meta, input.ModifierMeta},
+	"cmd":     {kb.Meta, input.ModifierMeta},
+	"command": {kb.Meta, input.ModifierMeta},
+	"shift":   {kb.Shift, input.ModifierShift},
+}
+
+// keyAliases are key names that differ from the DOM key values
+var keyAliases = map[string]string{
+	"esc":    "Escape",
+	"return": "Enter",
+	"space":  " ",
+	"del":    "Delete",
+	"up":     "ArrowUp",
+	"down":   "ArrowDown",
+	"left":   "ArrowLeft",
+	"right":  "ArrowRight",
+}
+
+// keyNames maps lower case DOM key values like enter or arrowdown to their
+// rune in kb.Keys
+var keyNames = sync.OnceValue(func() map[string]rune {
+	names := make(map[string]rune)
+	for r, k := range kb.Keys {
+		name := strings.ToLower(k.Key)
+		if old, ok := names[name]; !ok || r < old {
+			names[name] = r
+		}
+	}
+	return names
+})
+
+// keyPress is a key with the modifiers held while it is pressed
+type keyPress struct {
+	key       *kb.Key
+	modifiers []string
+	mask      input.Modifier
+}
+
+// parseKeyPress parses a key like Enter, a or F5, optionally combined with
+// modifiers like Control+Shift+T, plus the modifiers given separately
+func parseKeyPress(combo string, modifiers []string) (keyPress, error) {
+	var kp keyPress
+	parts := strings.Split(combo, "+")
+	if strings.HasSuffix(combo, "++") || combo == "+" {
+		// The plus key itself
+		parts = append(parts[:len(parts)-2], "+")
+	}
+	name := parts[len(parts)-1]
+	for _, m := range append(modifiers, parts[:len(parts)-1]...) {
+		mod, ok := modifierKeys[strings.ToLower(strings.TrimSpace(m))]
+		if !ok {
+			return kp, fmt.Errorf("unknown modifier: %s", m)
+		}
+		if kp.mask&mod.bit == 0 {
+			kp.modifiers = append(kp.modifiers, mod.key)
+			kp.mask |= mod.bit
+		}
+	}
+
+	if alias, ok := keyAliases[strings.ToLower(name)]; ok {
+		name = alias
+	}
+	var r rune
+	if utf8.RuneCountInString(name) == 1 {
+		r, _ = utf8.DecodeRuneInString(name)
+	} else if v, ok := keyNames()[strings.ToLower(name)]; ok {
+		r = v
+	} else {
+		return kp, fmt.Errorf("unknown key: %s", name)
+	}
+	k, ok := kb.Keys[r]
+	if !ok {
+		return kp, fmt.Errorf("unknown key: %s", name)
+	}
+	// Letters follow Shift, so Shift+t is T and Control+A is Control+a
+	shift := kp.mask&input.ModifierShift != 0
+	if shift && unicode.IsLower(r) {
+		if upper, ok := kb.Keys[unicode.ToUpper(r)]; ok {
+			k = upper
+		}
+	} else if !shift && kp.mask != 0 && unicode.IsUpper(r) {
+		if lower, ok := kb.Keys[unicode.ToLower(r)]; ok {
+			k = lower
+		}
+	}
+	kp.key = k
+	return kp, nil
+}
+
+// events returns the key events of the press: the modifiers go down, the key
+// goes down and up, the modifiers go up again
+func (kp keyPress) events() []*input.DispatchKeyEventParams {
+	var events, ups []*input.DispatchKeyEventParams
+	var held input.Modifier
+	for _, m := range kp.modifiers {
+		mk := kb.Keys[[]rune(m)[0]]
+		held |= modifierKeys[strings.ToLower(mk.Key)].bit
+		down := keyEvent(input.KeyRawDown, mk, held)
+		up := keyEvent(input.KeyUp, mk, held)
+		events = append(events, down)
+		ups = append([]*input.DispatchKeyEventParams{up}, ups...)
+	}
+
+	down := keyEvent(input.KeyRawDown, kp.key, kp.mask)
+	shortcut := kp.mask&(input.ModifierCtrl|input.ModifierAlt|input.ModifierMeta) != 0
+	if kp.key.Print && !shortcut {
+		// A keyDown with text also inserts it, like a real key press
+		down.Type = input.KeyDown
+		down.Text = kp.key.Text
+		down.UnmodifiedText = kp.key.Unmodified
+	}
+	if shortcut && strings.EqualFold(kp.key.Key, "a") {
+		// Editing shortcuts are not bound on every platform
+		down.Commands = []string{"selectAll"}
+	}
+	events = append(events, down, keyEvent(input.KeyUp, kp.key, kp.mask))
+	return append(events, ups...)
+}
+
+func keyEvent(typ input.KeyType, k *kb.Key, modifiers input.Modifier) *input.DispatchKeyEventParams {
+	return &input.DispatchKeyEventParams{
+		Type:                  typ,
+		Modifiers:             modifiers,
+		Key:                   k.Key,
+		Code:                  k.Code,
+		WindowsVirtualKeyCode: k.Windows,
+		NativeVirtualKeyCode:  k.Native,
+	}
+}
+
+func (bs *BrowserService) registerInputTools() {
+	bs.AddTool(mcp.NewTool(
+		"browser_press_key",
+		mcp.WithDescription("Press a key in the page, e.g. Enter, Escape, Tab, ArrowDown, a or a combination like Control+A"),
+		mcp.WithString("key",
+			mcp.Required(),
+			mcp.Description("The key to press, optionally with modifiers joined by +, e.g. Shift+Tab"),
+		),
+		mcp.WithArray("modifiers",
+			mcp.Description("Modifiers to hold while pressing the key"),
+			mcp.Items(map[string]interface{}{"type": "string", "enum": []string{"Alt", "Control", "Meta", "Shift"}}),
+		),
+		mcp.WithString("selector",
+			mcp.Description("The CSS selector of an element to focus first, default: the focused element"),
+		),
+		refArg(),
+		timeoutArg(bs.config.CSSTimeout),
+		tabArg(),
+	), bs.handlePressKey)
+
+	bs.AddTool(mcp.NewTool(
+		"browser_type",
+		mcp.WithDescription("Type text into the focused element key by key, unlike browser_fill this triggers the key events of every character"),
+		mcp.WithString("text",
+			mcp.Required(),
+			mcp.Description("The text to type"),
+		),
+		mcp.WithNumber("delay",
+			mcp.Description("Milliseconds to wait between keys"),
+			mcp.DefaultNumber(0),
+		),
+		mcp.WithString("selector",
+			mcp.Description("The CSS selector of an element to focus first, default: the focused element"),
+		),
+		refArg(),
+		timeoutArg(bs.config.Timeout),
+		tabArg(),
+	), bs.handleType)
+
+	bs.AddTool(mcp.NewTool(
+		"browser_scroll",
+		mcp.WithDescription("Scroll the page or an element: to an element, by an offset or to the top or bottom. Scrolling to the bottom repeatedly loads infinite scroll pages"),
+		mcp.WithString("selector",
+			mcp.Description("The CSS selector of the element to scroll into view, or with x or y of the element to scroll"),
+		),
+		refArg(),
+		mcp.WithNumber("x",
+			mcp.Description("Pixels to scroll right, negative to scroll left"),
+		),
+		mcp.WithNumber("y",
+			mcp.Description("Pixels to scroll down, negative to scroll up"),
+		),
+		mcp.WithString("to",
+			mcp.Description("Scroll the page to its top or bottom"),
+			mcp.Enum("top", "bottom"),
+		),
+		mcp.WithNumber("times",
+			mcp.Description("With to=bottom, how often to scroll to the bottom while the page keeps growing"),
+			mcp.DefaultNumber(1),
+		),
+		mcp.WithNumber("wait",
+			mcp.Description("Milliseconds to wait for new content after each scroll to the bottom"),
+			mcp.DefaultNumber(1000),
+		),
+		timeoutArg(bs.config.Timeout),
+		tabArg(),
+	), bs.handleScroll)
+
+	bs.AddTool(mcp.NewTool(
+		"browser_drag",
+		mcp.WithDescription("Drag from an element or point to another element or point with the mouse. Works for mouse-driven dragging as well as HTML5 drag and drop"),
+		mcp.WithString("from_selector",
+			mcp.Description("The CSS selector of the element to drag"),
+		),
+		mcp.WithString("from_ref",
+			mcp.Description("The ref of the element to drag from browser_snapshot"),
+		),
+		mcp.WithNumber("from_x",
+			mcp.Description("The viewport x coordinate to drag from, instead of an element"),
+		),
+		mcp.WithNumber("from_y",
+			mcp.Description("The viewport y coordinate to drag from, instead of an element"),
+		),
+		mcp.WithString("to_selector",
+			mcp.Description("The CSS selector of the element to drop on"),
+		),
+		mcp.WithString("to_ref",
+			mcp.Description("The ref of the element to drop on from browser_snapshot"),
+		),
+		mcp.WithNumber("to_x",
+			mcp.Description("The viewport x coordinate to drop at, instead of an element"),
+		),
+		mcp.WithNumber("to_y",
+			mcp.Description("The viewport y coordinate to drop at, instead of an element"),
+		),
+		mcp.WithNumber("steps",
+			mcp.Description(fmt.Sprintf("How many mouse moves the drag is split into, at most %d", maxDragSteps)),
+			mcp.DefaultNumber(defaultDragSteps),
+		),
+		timeoutArg(bs.config.CSSTimeout),
+		tabArg(),
+	), bs.handleDrag)
+}
+
+// focusAction focuses the element given by the selector or ref arguments, if
+// any, and returns a description of what has the focus. A ref is resolved
+// within d.
+func (bs *BrowserService) focusAction(ctx context.Context, t *tab, args map[string]interface{}, d time.Duration) (chromedp.Action, string, error) {
+	ref, _ := args["ref"].(string)
+	selector, _ := args["selector"].(string)
+	if ref == "" && selector == "" {
+		return chromedp.Tasks{}, "the focused element", nil
+	}
+	sel, opts, desc, err := bs.elementSelector(ctx, t, args, d)
+	if err != nil {
+		return nil, "", err
+	}
+	return chromedp.Focus(sel, append(opts, chromedp.NodeVisible)...), desc, nil
+}
+
+func dispatchKeys(events []*input.DispatchKeyEventParams) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		for _, ev := range events {
+			if err := ev.Do(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (bs *BrowserService) handlePressKey(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	key, _ := args["key"].(string)
+	if key == "" {
+		return mcp.NewToolResultError("key must be a non-empty string"), nil
+	}
+	var modifiers []string
+	raw, _ := args["modifiers"].([]interface{})
+	for _, v := range raw {
+		m, ok := v.(string)
+		if !ok {
+			return mcp.NewToolResultError("modifiers must be an array of strings"), nil
+		}
+		modifiers = append(modifiers, m)
+	}
+	kp, err := parseKeyPress(key, modifiers)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	t, err := bs.tabOf(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	focus, desc, err := bs.focusAction(ctx, t, args, timeout(args, bs.config.CSSTimeout))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := bs.run(ctx, t, timeout(args, bs.config.CSSTimeout), focus, dispatchKeys(kp.events())); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to press %s on %s: %v", key, desc, err)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Pressed %s on %s", key, desc)), nil
+}
+
+func (bs *BrowserService) handleType(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	text, ok := args["text"].(string)
+	if !ok {
+		return mcp.NewToolResultError("text must be a string"), nil
+	}
+	delayMS, _ := args["delay"].(float64)
+	delay := time.Duration(max(delayMS, 0) * float64(time.Millisecond))
+
+	t, err := bs.tabOf(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	d := timeout(args, bs.config.Timeout)
+	focus, desc, err := bs.focusAction(ctx, t, args, d)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	typeText := chromedp.ActionFunc(func(ctx context.Context) error {
+		for i, r := range []rune(text) {
+			if i > 0 && delay > 0 {
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			if err := dispatchKeys(kb.Encode(r)).Do(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err := bs.run(ctx, t, d, focus, typeText); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to type into %s: %v", desc, err)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Typed %d characters into %s", utf8.RuneCountInString(text), desc)), nil
+}
+
+// point is a position in viewport coordinates
+type point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// scrollPosition is the result of scrollPositionJS
+type scrollPosition struct {
+	X        float64 `json:"x"`
+	Y        float64 `json:"y"`
+	Height   float64 `json:"height"`
+	Viewport float64 `json:"viewport"`
+}
+
+// elementCenter finds the center of the element matching sel, scrolling it
+// into view first
+func elementCenter(sel interface{}, opts []chromedp.QueryOption, p *point) chromedp.Action {
+	var nodes []*cdp.Node
+	return chromedp.Tasks{
+		chromedp.Nodes(sel, &nodes, append(opts, chromedp.NodeVisible)...),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			return callOnNode(ctx, nodes[0].NodeID, elementCenterJS, p)
+		}),
+	}
+}
+
+// wheel scrolls by dx, dy with a mouse wheel event at p, which scrolls the
+// innermost scrollable element there
+func wheel(p *point, dx, dy float64) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		err := input.DispatchMouseEvent(input.MouseWheel, p.X, p.Y).WithDeltaX(dx).WithDeltaY(dy).Do(ctx)
+		if err != nil {
+			return err
+		}
+		return chromedp.Sleep(scrollSettle).Do(ctx)
+	})
+}
+
+// viewportCenter finds the center of the viewport
+func viewportCenter(p *point) chromedp.Action {
+	return chromedp.Evaluate(`({x: window.innerWidth / 2, y: window.innerHeight / 2})`, p)
+}
+
+func (bs *BrowserService) handleScroll(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	dx, hasX := args["x"].(float64)
+	dy, hasY := args["y"].(float64)
+	to, _ := args["to"].(string)
+	ref, _ := args["ref"].(string)
+	selector, _ := args["selector"].(string)
+	hasElement := ref != "" || selector != ""
+	hasOffset := hasX || hasY
+	if to != "" && to != "top" && to != "bottom" {
+		return mcp.NewToolResultError(fmt.Sprintf("unknown to: %s", to)), nil
+	}
+	if to != "" && (hasElement || hasOffset) {
+		return mcp.NewToolResultError("to cannot be combined with an element or an offset"), nil
+	}
+	if to == "" && !hasElement && !hasOffset {
+		return mcp.NewToolResultError("give an element, an offset or to"), nil
+	}
+
+	t, err := bs.tabOf(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	var (
+		at          point
+		pos         scrollPosition
+		actions     []chromedp.Action
+		description string
+	)
+	switch {
+	case hasElement:
+		sel, opts, desc, err := bs.elementSelector(ctx, t, args, timeout(args, bs.config.Timeout))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		actions = append(actions, elementCenter(sel, opts, &at))
+		description = "to " + desc
+		if hasOffset {
+			actions = append(actions, wheel(&at, dx, dy))
+			description = fmt.Sprintf("%s by %v, %v", desc, dx, dy)
+		}
+	case hasOffset:
+		actions = append(actions, viewportCenter(&at), wheel(&at, dx, dy))
+		description = fmt.Sprintf("the page by %v, %v", dx, dy)
+	default:
+		times := 1
+		if v, ok := args["times"].(float64); ok && v > 1 {
+			times = int(v)
+		}
+		wait := time.Second
+		if v, ok := args["wait"].(float64); ok && v >= 0 {
+			wait = time.Duration(v * float64(time.Millisecond))
+		}
+		actions = append(actions, scrollToEnd(to, times, wait, &description))
+	}
+	actions = append(actions, chromedp.Evaluate(scrollPositionJS, &pos))
+
+	if err := bs.run(ctx, t, timeout(args, bs.config.Timeout), actions...); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to scroll: %v", err)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Scrolled %s, the page is now at x=%v, y=%v of height %v", description, pos.X, pos.Y, pos.Height)), nil
+}
+
+// scrollToEnd scrolls the page to its top, or to its bottom up to times
+// times for as long as the page grows, waiting wait each time for content to
+// load. It scrolls the window itself, a wheel event could land on an inner
+// scroll container. It describes what it did in desc.
+func scrollToEnd(to string, times int, wait time.Duration, desc *string) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if to == "top" {
+			*desc = "to the top"
+			return scrollWindow(`window.scrollTo(0, 0)`).Do(ctx)
+		}
+		var pos scrollPosition
+		if err := chromedp.Evaluate(scrollPositionJS, &pos).Do(ctx); err != nil {
+			return err
+		}
+		for i := 1; i <= times; i++ {
+			height := pos.Height
+			if err := scrollWindow(`window.scrollTo(window.scrollX, document.documentElement.scrollHeight)`).Do(ctx); err != nil {
+				return err
+			}
+			*desc = fmt.Sprintf("to the bottom %d time(s)", i)
+			if i == times {
+				return nil
+			}
+			if err := chromedp.Sleep(wait).Do(ctx); err != nil {
+				return err
+			}
+			if err := chromedp.Evaluate(scrollPositionJS, &pos).Do(ctx); err != nil {
+				return err
+			}
+			if pos.Height <= height {
+				*desc += ", the page stopped growing"
+				return nil
+			}
+		}
+		return nil
+	})
+}
+
+// scrollWindow runs a window.scrollTo expression and lets it take effect
+func scrollWindow(js string) chromedp.Action {
+	return chromedp.Tasks{
+		chromedp.Evaluate(js, nil),
+		chromedp.Sleep(scrollSettle),
+	}
+}
+
+// dragEnd returns the action that finds one end of a drag, from the
+// prefixed selector, ref or coordinate arguments
+func (bs *BrowserService) dragEnd(ctx context.Context, t *tab, args map[string]interface{}, prefix string, p *point) (chromedp.Action, string, error) {
+	x, hasX := args[prefix+"x"].(float64)
+	y, hasY := args[prefix+"y"].(float64)
+	ref, _ := args[prefix+"ref"].(string)
+	selector, _ := args[prefix+"selector"].(string)
+	if ref != "" || selector != "" {
+		sel, opts, desc, err := bs.elementSelector(ctx, t, map[string]interface{}{"ref": ref, "selector": selector}, timeout(args, bs.config.CSSTimeout))
+		if err != nil {
+			return nil, "", err
+		}
+		return elementCenter(sel, opts, p), desc, nil
+	}
+	if !hasX || !hasY {
+		return nil, "", fmt.Errorf("give %sselector, %sref or both %sx and %sy", prefix, prefix, prefix, prefix)
+	}
+	*p = point{X: x, Y: y}
+	return chromedp.Tasks{}, fmt.Sprintf("(%v, %v)", x, y), nil
+}
+
+// dragPath returns the points the mouse moves through from a to b
+func dragPath(a, b point, steps int) []point {
+	steps = min(max(steps, 1), maxDragSteps)
+	path := make([]point, steps)
+	for i := 1; i <= steps; i++ {
+		f := float64(i) / float64(steps)
+		path[i-1] = point{X: a.X + (b.X-a.X)*f, Y: a.Y + (b.Y-a.Y)*f}
+	}
+	return path
+}
+
+func (bs *BrowserService) handleDrag(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	steps := defaultDragSteps
+	if v, ok := args["steps"].(float64); ok && v >= 1 {
+		steps = int(min(v, maxDragSteps))
+	}
+	t, err := bs.tabOf(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	var from, to point
+	findFrom, fromDesc, err := bs.dragEnd(ctx, t, args, "from_", &from)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	findTo, toDesc, err := bs.dragEnd(ctx, t, args, "to_", &to)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	drag := chromedp.ActionFunc(func(ctx context.Context) error {
+		// The source is found first so it is in view when the mouse goes down,
+		// the target is found again in case that scrolled the page.
+		if err := findFrom.Do(ctx); err != nil {
+			return err
+		}
+		// HTML5 drag and drop does not complete on mouse events alone. With
+		// drags intercepted Chrome hands over the drag data once the page
+		// starts one, and the rest of it is done with drag events.
+		intercepted := make(chan *input.DragData, 1)
+		chromedp.ListenTarget(ctx, func(ev interface{}) {
+			if e, ok := ev.(*input.EventDragIntercepted); ok {
+				select {
+				case intercepted <- e.Data:
+				default:
+				}
+			}
+		})
+		if err := input.SetInterceptDrags(true).Do(ctx); err != nil {
+			return err
+		}
+		// Cleaning up runs on a fresh context, so it also happens when the
+		// drag ran out of time.
+		cleanup := func(f func(ctx context.Context) error) error {
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), dragCleanupTimeout)
+			defer cancel()
+			return f(ctx)
+		}
+		defer cleanup(func(ctx context.Context) error {
+			return input.SetInterceptDrags(false).Do(ctx)
+		})
+
+		if err := input.DispatchMouseEvent(input.MouseMoved, from.X, from.Y).Do(ctx); err != nil {
+			return err
+		}
+		err := input.DispatchMouseEvent(input.MousePressed, from.X, from.Y).
+			WithButton(input.Left).WithButtons(1).WithClickCount(1).Do(ctx)
+		if err != nil {
+			return err
+		}
+
+		at := from
+		var data *input.DragData
+		dropped := false
+		// startDrag enters the page's drag at p once it has started one
+		startDrag := func(p point) error {
+			select {
+			case data = <-intercepted:
+			default:
+				return nil
+			}
+			if err := input.DispatchDragEvent(input.DragEnter, p.X, p.Y, data).Do(ctx); err != nil {
+				return err
+			}
+			return input.DispatchDragEvent(input.DragOver, p.X, p.Y, data).Do(ctx)
+		}
+		err = func() error {
+			if err := findTo.Do(ctx); err != nil {
+				return err
+			}
+			for _, p := range dragPath(from, to, steps) {
+				if data != nil {
+					if err := input.DispatchDragEvent(input.DragOver, p.X, p.Y, data).Do(ctx); err != nil {
+						return err
+					}
+				} else {
+					if err := input.DispatchMouseEvent(input.MouseMoved, p.X, p.Y).WithButton(input.Left).WithButtons(1).Do(ctx); err != nil {
+						return err
+					}
+					if err := startDrag(p); err != nil {
+						return err
+					}
+				}
+				at = p
+			}
+			if data == nil {
+				// The drag may only be reported after the last move.
+				if err := chromedp.Sleep(dragStartWait).Do(ctx); err != nil {
+					return err
+				}
+				if err := startDrag(at); err != nil {
+					return err
+				}
+			}
+			if data != nil {
+				if err := input.DispatchDragEvent(input.Drop, at.X, at.Y, data).Do(ctx); err != nil {
+					return err
+				}
+				dropped = true
+			}
+			return nil
+		}()
+
+		// Once the button is down it has to come up again, or the page is left
+		// in the middle of a drag.
+		releaseErr := cleanup(func(ctx context.Context) error {
+			if data != nil && !dropped {
+				_ = input.DispatchDragEvent(input.DragCancel, at.X, at.Y, data).Do(ctx)
+			}
+			return input.DispatchMouseEvent(input.MouseReleased, at.X, at.Y).
+				WithButton(input.Left).WithButtons(0).WithClickCount(1).Do(ctx)
+		})
+		if err != nil {
+			return err
+		}
+		return releaseErr
+	})
+	if err := bs.run(ctx, t, timeout(args, bs.config.CSSTimeout), drag); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to drag %s to %s: %v", fromDesc, toDesc, err)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Dragged %s to %s", fromDesc, toDesc)), nil
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/chromedp/cdproto/input"
+)
+
+func TestParseKeyPress(t *testing.T) {
+	tests := []struct {
+		combo     string
+		modifiers []string
+		key       string
+		mask      input.Modifier
+	}{
+		{"Enter", nil, "Enter", 0},
+		{"esc", nil, "Escape", 0},
+		{"ArrowDown", nil, "ArrowDown", 0},
+		{"F5", nil, "F5", 0},
+		{"Control+a", nil, "a", input.ModifierCtrl},
+		{"Shift+Tab", nil, "Tab", input.ModifierShift},
+		{"t", []string{"Meta", "shift"}, "T", input.ModifierMeta | input.ModifierShift},
+		{"Ctrl++", nil, "+", input.ModifierCtrl},
+	}
+	for _, tt := range tests {
+		kp, err := parseKeyPress(tt.combo, tt.modifiers)
+		if err != nil {
+			t.Errorf("parseKeyPress(%q): %v", tt.combo, err)
+			continue
+		}
+		if kp.key.Key != tt.key || kp.mask != tt.mask {
+			t.Errorf("parseKeyPress(%q) = %s with %d, want %s with %d", tt.combo, kp.key.Key, kp.mask, tt.key, tt.mask)
+		}
+	}
+	for _, combo := range []string{"Hyperdrive", "Super+a"} {
+		if _, err := parseKeyPress(combo, nil); err == nil {
+			t.Errorf("expected an error for %q", combo)
+		}
+	}
+}
+
+func TestKeyPressEvents(t *testing.T) {
+	kp, err := parseKeyPress("Control+A", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := kp.events()
+	var got []string
+	for _, ev := range events {
+		got = append(got, string(ev.Type)+" "+ev.Key)
+	}
+	want := []string{"rawKeyDown Control", "rawKeyDown a", "keyUp a", "keyUp Control"}
+	if len(got) != len(want) {
+		t.Fatalf("got events %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d is %q, want %q", i, got[i], want[i])
+		}
+	}
+	if events[1].Text != "" || len(events[1].Commands) != 1 || events[1].Modifiers != input.ModifierCtrl {
+		t.Errorf("unexpected shortcut event: %+v", events[1])
+	}
+
+	kp, _ = parseKeyPress("a", nil)
+	if down := kp.events()[0]; down.Type != input.KeyDown || down.Text != "a" {
+		t.Errorf("expected a key down inserting the text, got %+v", down)
+	}
+}
+
+func TestDragPath(t *testing.T) {
+	path := dragPath(point{0, 0}, point{100, 50}, 4)
+	if len(path) != 4 || path[0] != (point{25, 12.5}) || path[3] != (point{100, 50}) {
+		t.Errorf("unexpected path: %v", path)
+	}
+	if path := dragPath(point{1, 1}, point{2, 2}, 0); len(path) != 1 || path[0] != (point{2, 2}) {
+		t.Errorf("unexpected single step path: %v", path)
+	}
+	if path := dragPath(point{0, 0}, point{1, 1}, 1e6); len(path) != maxDragSteps {
+		t.Errorf("expected the path to be capped at %d steps, got %d", maxDragSteps, len(path))
+	}
+}
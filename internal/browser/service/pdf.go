@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// paperSizes are the paper sizes browser_pdf knows, width x height in inches
+var paperSizes = map[string][2]float64{
+	"letter":  {8.5, 11},
+	"legal":   {8.5, 14},
+	"tabloid": {11, 17},
+	"a3":      {11.69, 16.54},
+	"a4":      {8.27, 11.69},
+	"a5":      {5.83, 8.27},
+}
+
+// pageRangesRe matches page ranges like "1-5, 8, 11-13", either end of a
+// range may be left open but not both
+var pageRangesRe = regexp.MustCompile(`^\s*((\d+(-\d*)?|-\d+)(\s*,\s*(\d+(-\d*)?|-\d+))*)?\s*$`)
+
+// defaultPDFMargin is Chrome's default margin in inches
+const defaultPDFMargin = 0.4
+
+func (bs *BrowserService) registerPDFTool() {
+	bs.AddTool(mcp.NewTool(
+		"browser_pdf",
+		mcp.WithDescription("Print the current page to PDF and return it as an embedded resource, or save it and return its path"),
+		mcp.WithString("name",
+			mcp.Description("The name of the PDF, used for the file name when it is saved"),
+		),
+		mcp.WithString("paper",
+			mcp.Description("The paper size"),
+			mcp.DefaultString("letter"),
+			mcp.Enum("letter", "legal", "tabloid", "a3", "a4", "a5"),
+		),
+		mcp.WithNumber("width",
+			mcp.Description("The paper width in inches, overrides paper"),
+		),
+		mcp.WithNumber("height",
+			mcp.Description("The paper height in inches, overrides paper"),
+		),
+		mcp.WithObject("margins",
+			mcp.Description(fmt.Sprintf("Page margins in inches, default: %v on every side", defaultPDFMargin)),
+			mcp.Properties(map[string]interface{}{
+				"top":    map[string]interface{}{"type": "number"},
+				"right":  map[string]interface{}{"type": "number"},
+				"bottom": map[string]interface{}{"type": "number"},
+				"left":   map[string]interface{}{"type": "number"},
+			}),
+		),
+		mcp.WithBoolean("landscape",
+			mcp.Description("Print in landscape orientation"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithBoolean("print_background",
+			mcp.Description("Print background colors and images"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithNumber("scale",
+			mcp.Description("Scale of the page rendering, from 0.1 to 2"),
+			mcp.DefaultNumber(1),
+		),
+		mcp.WithString("page_ranges",
+			mcp.Description("Pages to print, e.g. 1-5, 8, 11-13, default: all pages"),
+		),
+		mcp.WithString("header_template",
+			mcp.Description("HTML of the page header. Elements with the classes date, title, url, pageNumber and totalPages get the matching values"),
+		),
+		mcp.WithString("footer_template",
+			mcp.Description("HTML of the page footer, with the same classes as header_template"),
+		),
+		mcp.WithBoolean("prefer_css_page_size",
+			mcp.Description("Use the page size the page's CSS @page rule defines over paper, width and height"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithBoolean("save",
+			mcp.Description("Save the PDF in the data directory and return its path instead of its content"),
+			mcp.DefaultBool(false),
+		),
+		timeoutArg(bs.config.Timeout),
+		tabArg(),
+	), bs.handlePDF)
+}
+
+// parsePDFOptions turns the arguments of browser_pdf into print parameters
+func parsePDFOptions(args map[string]interface{}) (*page.PrintToPDFParams, error) {
+	paper, _ := args["paper"].(string)
+	if paper == "" {
+		paper = "letter"
+	}
+	size, ok := paperSizes[strings.ToLower(paper)]
+	if !ok {
+		return nil, fmt.Errorf("unknown paper size: %s", paper)
+	}
+	if v, ok := args["width"].(float64); ok && v > 0 {
+		size[0] = v
+	}
+	if v, ok := args["height"].(float64); ok && v > 0 {
+		size[1] = v
+	}
+
+	margins := map[string]float64{"top": defaultPDFMargin, "right": defaultPDFMargin, "bottom": defaultPDFMargin, "left": defaultPDFMargin}
+	if m, ok := args["margins"].(map[string]interface{}); ok {
+		for side := range margins {
+			if v, ok := m[side].(float64); ok {
+				if v < 0 {
+					return nil, fmt.Errorf("the %s margin must not be negative", side)
+				}
+				margins[side] = v
+			}
+		}
+	}
+
+	scale := 1.0
+	if v, ok := args["scale"].(float64); ok {
+		if v < 0.1 || v > 2 {
+			return nil, fmt.Errorf("scale must be between 0.1 and 2")
+		}
+		scale = v
+	}
+
+	pageRanges, _ := args["page_ranges"].(string)
+	if !pageRangesRe.MatchString(pageRanges) {
+		return nil, fmt.Errorf("invalid page ranges: %s", pageRanges)
+	}
+
+	landscape, _ := args["landscape"].(bool)
+	background, _ := args["print_background"].(bool)
+	preferCSS, _ := args["prefer_css_page_size"].(bool)
+	header, _ := args["header_template"].(string)
+	footer, _ := args["footer_template"].(string)
+
+	params := page.PrintToPDF().
+		WithPaperWidth(size[0]).
+		WithPaperHeight(size[1]).
+		WithMarginTop(margins["top"]).
+		WithMarginRight(margins["right"]).
+		WithMarginBottom(margins["bottom"]).
+		WithMarginLeft(margins["left"]).
+		WithLandscape(landscape).
+		WithPrintBackground(background).
+		WithScale(scale).
+		WithPageRanges(strings.TrimSpace(pageRanges)).
+		WithPreferCSSPageSize(preferCSS)
+	if header != "" || footer != "" {
+		// Chrome prints its own default for a template left empty, a blank
+		// span hides it instead.
+		if header == "" {
+			header = "<span></span>"
+		}
+		if footer == "" {
+			footer = "<span></span>"
+		}
+		params = params.WithDisplayHeaderFooter(true).WithHeaderTemplate(header).WithFooterTemplate(footer)
+	}
+	return params, nil
+}
+
+func (bs *BrowserService) handlePDF(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	params, err := parsePDFOptions(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	t, err := bs.tabOf(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	var (
+		buf      []byte
+		location string
+	)
+	err = bs.run(ctx, t, timeout(args, bs.config.Timeout),
+		chromedp.Location(&location),
+		chromedp.ActionFunc(func(ctx context.Context) (err error) {
+			buf, _, err = params.Do(ctx)
+			return err
+		}),
+	)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to print PDF: %v", err)), nil
+	}
+
+	if save, _ := args["save"].(bool); !save {
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.NewEmbeddedResource(mcp.BlobResourceContents{
+					URI:      location,
+					MIMEType: "application/pdf",
+					Blob:     base64.StdEncoding.EncodeToString(buf),
+				}),
+			},
+		}, nil
+	}
+	name, _ := args["name"].(string)
+	if name == "" {
+		name = "page"
+	}
+	path := filepath.Join(bs.config.DataPath, fmt.Sprintf("%s_%d.pdf", filepath.Base(name), time.Now().Unix()))
+	if err := os.WriteFile(path, buf, 0644); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to save PDF: %v", err)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("PDF of %s saved to %s (%d bytes)", location, path, len(buf))), nil
+}
@@ -0,0 +1,52 @@
+package service
+
+import "testing"
+
+func TestParsePDFOptions(t *testing.T) {
+	params, err := parsePDFOptions(map[string]interface{}{
+		"paper":           "A4",
+		"margins":         map[string]interface{}{"top": float64(1), "left": float64(0)},
+		"page_ranges":     "1-3, 5",
+		"footer_template": `<span class="pageNumber"></span>`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.PaperWidth != 8.27 || params.PaperHeight != 11.69 {
+		t.Errorf("unexpected paper size: %v x %v", params.PaperWidth, params.PaperHeight)
+	}
+	if params.MarginTop != 1 || params.MarginLeft != 0 || params.MarginBottom != defaultPDFMargin {
+		t.Errorf("unexpected margins: %+v", params)
+	}
+	if !params.DisplayHeaderFooter || params.HeaderTemplate != "<span></span>" || params.PageRanges != "1-3, 5" {
+		t.Errorf("unexpected header and footer: %+v", params)
+	}
+
+	params, err = parsePDFOptions(map[string]interface{}{"width": float64(4), "landscape": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if params.PaperWidth != 4 || params.PaperHeight != 11 || !params.Landscape || params.DisplayHeaderFooter {
+		t.Errorf("unexpected options: %+v", params)
+	}
+
+	for _, ranges := range []string{"", "7", "1-3, 5", "-4", "9-", " 2 , 4-6 "} {
+		if _, err := parsePDFOptions(map[string]interface{}{"page_ranges": ranges}); err != nil {
+			t.Errorf("expected page ranges %q to be accepted: %v", ranges, err)
+		}
+	}
+
+	for _, args := range []map[string]interface{}{
+		{"paper": "b5"},
+		{"scale": float64(3)},
+		{"page_ranges": "1-a"},
+		{"page_ranges": ",,,"},
+		{"page_ranges": "-"},
+		{"page_ranges": "1,,2"},
+		{"margins": map[string]interface{}{"right": float64(-1)}},
+	} {
+		if _, err := parsePDFOptions(args); err == nil {
+			t.Errorf("expected an error for %v", args)
+		}
+	}
+}
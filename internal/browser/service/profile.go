@@ -0,0 +1,206 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// lockFileName is created in every profile in use and locked for as long as
+// the browser runs on it
+const lockFileName = "mcp.lock"
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// errProfileLocked is returned when another process uses a profile
+var errProfileLocked = errors.New("profile is in use by another process")
+
+// browserProfile is the Chrome user data directory the browser runs on
+type browserProfile struct {
+	name      string
+	dir       string
+	ephemeral bool
+	lock      *os.File
+}
+
+// openProfile creates the named profile under root if needed and locks it.
+// An ephemeral profile lives in a temporary directory instead.
+func openProfile(root, name string, ephemeral bool) (*browserProfile, error) {
+	p := &browserProfile{name: name, ephemeral: ephemeral}
+	if ephemeral {
+		dir, err := os.MkdirTemp("", "browser_mcp_profile_")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create ephemeral profile: %v", err)
+		}
+		p.dir = dir
+		if p.name == "" {
+			p.name = "ephemeral"
+		}
+		return p, nil
+	}
+
+	if !profileNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("invalid profile name %q, use letters, digits, '.', '_' and '-'", name)
+	}
+	p.dir = filepath.Join(root, name)
+	if err := os.MkdirAll(p.dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create profile directory: %v", err)
+	}
+	f, err := lockProfileDir(p.dir)
+	if errors.Is(err, errProfileLocked) {
+		return nil, fmt.Errorf("%w: %s", err, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.lock = f
+	return p, nil
+}
+
+func lockProfileDir(dir string) (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(dir, lockFileName), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open profile lock: %v", err)
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, errProfileLocked
+	}
+	return f, nil
+}
+
+// LockProfileDir takes the lock the browser service holds on a Chrome user
+// data directory while it runs on it, so other programs can stay off a
+// profile that is in use. unlock releases it again.
+func LockProfileDir(dir string) (unlock func(), err error) {
+	f, err := lockProfileDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// Close releases the profile, removing it when it is ephemeral
+func (p *browserProfile) Close() error {
+	if p.ephemeral {
+		return os.RemoveAll(p.dir)
+	}
+	if p.lock == nil {
+		return nil
+	}
+	unlockFile(p.lock)
+	err := p.lock.Close()
+	p.lock = nil
+	return err
+}
+
+// profileInfo is a profile as reported by browser_profile_list
+type profileInfo struct {
+	Name      string `json:"name"`
+	Current   bool   `json:"current,omitempty"`
+	Locked    bool   `json:"locked,omitempty"`
+	Ephemeral bool   `json:"ephemeral,omitempty"`
+}
+
+// listProfiles returns the profiles under root and whether another process
+// holds their lock
+func listProfiles(root string) ([]profileInfo, error) {
+	entries, err := os.ReadDir(root)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	var profiles []profileInfo
+	for _, e := range entries {
+		if !e.IsDir() || !profileNamePattern.MatchString(e.Name()) {
+			continue
+		}
+		info := profileInfo{Name: e.Name()}
+		if f, err := os.OpenFile(filepath.Join(root, e.Name(), lockFileName), os.O_RDWR, 0); err == nil {
+			if lockFile(f) != nil {
+				info.Locked = true
+			} else {
+				unlockFile(f)
+			}
+			f.Close()
+		}
+		profiles = append(profiles, info)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+	return profiles, nil
+}
+
+func (bs *BrowserService) registerProfileTools() {
+	bs.AddTool(mcp.NewTool(
+		"browser_profile_list",
+		mcp.WithDescription("List the browser profiles, which keep cookies, logins and storage between sessions"),
+	), bs.handleProfileList)
+
+	bs.AddTool(mcp.NewTool(
+		"browser_profile_switch",
+		mcp.WithDescription("Restart the browser on another profile, creating it if it does not exist. All tabs are closed"),
+		mcp.WithString("name",
+			mcp.Description("The name of the profile"),
+		),
+		mcp.WithBoolean("ephemeral",
+			mcp.Description("Use a throwaway profile that is deleted when the browser closes"),
+			mcp.DefaultBool(false),
+		),
+	), bs.handleProfileSwitch)
+}
+
+func (bs *BrowserService) handleProfileList(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	profiles, err := listProfiles(bs.config.ProfilesDir())
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list profiles: %v", err)), nil
+	}
+
+	bs.mu.Lock()
+	current := bs.profile
+	bs.mu.Unlock()
+	if current.ephemeral {
+		profiles = append([]profileInfo{{Name: current.name, Current: true, Ephemeral: true}}, profiles...)
+	}
+	for i := range profiles {
+		if !current.ephemeral && profiles[i].Name == current.name {
+			// The lock is ours.
+			profiles[i].Current = true
+			profiles[i].Locked = false
+		}
+	}
+
+	payload, err := json.MarshalIndent(profiles, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(string(payload)), nil
+}
+
+func (bs *BrowserService) handleProfileSwitch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	name, _ := request.Params.Arguments["name"].(string)
+	ephemeral, _ := request.Params.Arguments["ephemeral"].(bool)
+	if name == "" && !ephemeral {
+		return mcp.NewToolResultError("name is required unless ephemeral is set"), nil
+	}
+
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if !ephemeral && !bs.profile.ephemeral && name == bs.profile.name {
+		return mcp.NewToolResultText(fmt.Sprintf("Already using profile %s", name)), nil
+	}
+	profile, err := openProfile(bs.config.ProfilesDir(), name, ephemeral)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	bs.resetBrowserLocked(profile)
+	return mcp.NewToolResultText(fmt.Sprintf("Switched to profile %s", profile.name)), nil
+}
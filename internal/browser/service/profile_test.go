@@ -0,0 +1,49 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestOpenProfile(t *testing.T) {
+	root := t.TempDir()
+	p, err := openProfile(root, "work", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openProfile(root, "work", false); !errors.Is(err, errProfileLocked) {
+		t.Errorf("expected the profile to be locked, got %v", err)
+	}
+	if _, err := openProfile(root, "../escape", false); err == nil {
+		t.Error("expected an error for an invalid name")
+	}
+
+	profiles, err := listProfiles(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 1 || profiles[0].Name != "work" || !profiles[0].Locked {
+		t.Errorf("unexpected profiles: %+v", profiles)
+	}
+
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	p, err = openProfile(root, "work", false)
+	if err != nil {
+		t.Fatalf("expected the profile to be free after close: %v", err)
+	}
+	p.Close()
+
+	e, err := openProfile(root, "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(e.dir); !os.IsNotExist(err) {
+		t.Errorf("expected the ephemeral profile to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,304 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// elementRectJS returns the border box of an element in page coordinates
+const elementRectJS = `function() {
+	const r = this.getBoundingClientRect();
+	return {x: r.left + window.scrollX, y: r.top + window.scrollY, width: r.width, height: r.height};
+}`
+
+// metricsRestoreTimeout bounds putting the viewport back after a screenshot,
+// which also happens when the screenshot itself timed out
+const metricsRestoreTimeout = 5 * time.Second
+
+// metricsOverrides keeps the device metrics overrides active on a tab, so
+// ending one puts back the one it replaced instead of clearing them all
+type metricsOverrides struct {
+	mu     sync.Mutex
+	active []*emulation.SetDeviceMetricsOverrideParams
+}
+
+// push applies p and returns the function that undoes it
+func (m *metricsOverrides) push(ctx context.Context, p *emulation.SetDeviceMetricsOverrideParams) (func(), error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := p.Do(ctx); err != nil {
+		return nil, err
+	}
+	m.active = append(m.active, p)
+	return func() {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), metricsRestoreTimeout)
+		defer cancel()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.active = slices.DeleteFunc(m.active, func(o *emulation.SetDeviceMetricsOverrideParams) bool { return o == p })
+		if n := len(m.active); n > 0 {
+			_ = m.active[n-1].Do(ctx)
+		} else {
+			_ = emulation.ClearDeviceMetricsOverride().Do(ctx)
+		}
+	}, nil
+}
+
+// screenshotOptions are the parsed arguments of browser_screenshot
+type screenshotOptions struct {
+	width, height int64
+	fullPage      bool
+	format        page.CaptureScreenshotFormat
+	quality       int64
+	clip          *page.Viewport
+	maxDimension  float64
+}
+
+func (bs *BrowserService) newScreenshotTool() mcp.Tool {
+	return mcp.NewTool(
+		"browser_screenshot",
+		mcp.WithDescription("Take a screenshot of the current page, an element or a region and return it as an image"),
+		mcp.WithString("name",
+			mcp.Description("The name of the screenshot, used for the file name when it is saved"),
+		),
+		mcp.WithString("selector",
+			mcp.Description("The CSS selector of the element to screenshot"),
+		),
+		refArg(),
+		mcp.WithBoolean("full_page",
+			mcp.Description("Capture the whole page instead of the visible viewport"),
+			mcp.DefaultBool(true),
+		),
+		mcp.WithNumber("width",
+			mcp.Description("The viewport width in pixels to render the page at, default: the window width"),
+		),
+		mcp.WithNumber("height",
+			mcp.Description("The viewport height in pixels to render the page at, default: the window height"),
+		),
+		mcp.WithString("format",
+			mcp.Description("The image format"),
+			mcp.DefaultString("png"),
+			mcp.Enum("png", "jpeg", "webp"),
+		),
+		mcp.WithNumber("quality",
+			mcp.Description("Compression quality from 0 to 100 for jpeg and webp"),
+			mcp.DefaultNumber(80),
+		),
+		mcp.WithObject("clip",
+			mcp.Description("Region of the page to capture in CSS pixels"),
+			mcp.Properties(map[string]interface{}{
+				"x":      map[string]interface{}{"type": "number"},
+				"y":      map[string]interface{}{"type": "number"},
+				"width":  map[string]interface{}{"type": "number"},
+				"height": map[string]interface{}{"type": "number"},
+			}),
+		),
+		mcp.WithNumber("max_dimension",
+			mcp.Description("Scale the image down so neither side is longer than this many pixels, 0 to keep the full size"),
+			mcp.DefaultNumber(2000),
+		),
+		mcp.WithBoolean("save",
+			mcp.Description("Also save the screenshot in the data directory and return its path"),
+			mcp.DefaultBool(false),
+		),
+		timeoutArg(bs.config.Timeout),
+		tabArg(),
+	)
+}
+
+func (bs *BrowserService) handleScreenshot(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	opts, err := parseScreenshotOptions(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	t, err := bs.tabOf(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	var (
+		sel       interface{}
+		queryOpts []chromedp.QueryOption
+	)
+	ref, _ := args["ref"].(string)
+	selector, _ := args["selector"].(string)
+	if ref != "" || selector != "" {
+		sel, queryOpts, _, err = bs.elementSelector(ctx, t, args, timeout(args, bs.config.Timeout))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+	}
+
+	var buf []byte
+	err = bs.run(ctx, t, timeout(args, bs.config.Timeout), captureScreenshot(sel, queryOpts, opts, &t.metrics, &buf))
+	if err != nil {
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to take screenshot: %v", err),
+				},
+			},
+			IsError: true,
+		}, nil
+	}
+
+	result := &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.NewImageContent(base64.StdEncoding.EncodeToString(buf), "image/"+string(opts.format)),
+		},
+	}
+	if save, _ := args["save"].(bool); save {
+		name, _ := args["name"].(string)
+		if name == "" {
+			name = "screenshot"
+		}
+		newName := filepath.Join(bs.config.DataPath, fmt.Sprintf("%s_%d.%s", filepath.Base(name), time.Now().Unix(), opts.format))
+		if err := os.WriteFile(newName, buf, 0644); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to save screenshot: %v", err)), nil
+		}
+		result.Content = append(result.Content, mcp.TextContent{
+			Type: "text",
+			Text: fmt.Sprintf("Screenshot saved to %s", newName),
+		})
+	}
+	return result, nil
+}
+
+func parseScreenshotOptions(args map[string]interface{}) (screenshotOptions, error) {
+	opts := screenshotOptions{
+		fullPage:     true,
+		format:       page.CaptureScreenshotFormatPng,
+		quality:      80,
+		maxDimension: 2000,
+	}
+	if v, ok := args["full_page"].(bool); ok {
+		opts.fullPage = v
+	}
+	if v, ok := args["width"].(float64); ok && v > 0 {
+		opts.width = int64(v)
+	}
+	if v, ok := args["height"].(float64); ok && v > 0 {
+		opts.height = int64(v)
+	}
+	if v, ok := args["format"].(string); ok && v != "" {
+		switch f := page.CaptureScreenshotFormat(v); f {
+		case page.CaptureScreenshotFormatPng, page.CaptureScreenshotFormatJpeg, page.CaptureScreenshotFormatWebp:
+			opts.format = f
+		default:
+			return opts, fmt.Errorf("unsupported format: %s", v)
+		}
+	}
+	if v, ok := args["quality"].(float64); ok {
+		opts.quality = int64(min(max(v, 0), 100))
+	}
+	if v, ok := args["max_dimension"].(float64); ok {
+		opts.maxDimension = max(v, 0)
+	}
+	if v, ok := args["clip"].(map[string]interface{}); ok {
+		clip := &page.Viewport{Scale: 1}
+		clip.X, _ = v["x"].(float64)
+		clip.Y, _ = v["y"].(float64)
+		clip.Width, _ = v["width"].(float64)
+		clip.Height, _ = v["height"].(float64)
+		if clip.Width <= 0 || clip.Height <= 0 {
+			return opts, fmt.Errorf("clip needs a positive width and height")
+		}
+		opts.clip = clip
+	}
+	return opts, nil
+}
+
+// fitScale returns the scale that makes a width x height image fit into
+// maxDimension on both sides, never enlarging it
+func fitScale(width, height, maxDimension float64) float64 {
+	longest := max(width, height)
+	if maxDimension <= 0 || longest <= maxDimension {
+		return 1
+	}
+	return maxDimension / longest
+}
+
+// captureScreenshot captures the element matching sel, or when sel is nil the
+// clip region, the full page or the viewport, resizing the viewport through
+// overrides first when a width or height is set.
+func captureScreenshot(sel interface{}, queryOpts []chromedp.QueryOption, opts screenshotOptions, overrides *metricsOverrides, buf *[]byte) chromedp.Action {
+	var nodes []*cdp.Node
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		if opts.width > 0 || opts.height > 0 {
+			_, _, _, layout, _, _, err := page.GetLayoutMetrics().Do(ctx)
+			if err != nil {
+				return err
+			}
+			width, height := opts.width, opts.height
+			if width == 0 {
+				width = layout.ClientWidth
+			}
+			if height == 0 {
+				height = layout.ClientHeight
+			}
+			restore, err := overrides.push(ctx, emulation.SetDeviceMetricsOverride(width, height, 1, false))
+			if err != nil {
+				return err
+			}
+			defer restore()
+		}
+
+		var clip page.Viewport
+		switch {
+		case sel != nil:
+			if err := chromedp.Nodes(sel, &nodes, append(queryOpts, chromedp.NodeVisible)...).Do(ctx); err != nil {
+				return err
+			}
+			if err := callOnNode(ctx, nodes[0].NodeID, elementRectJS, &clip); err != nil {
+				return err
+			}
+		case opts.clip != nil:
+			clip = *opts.clip
+		default:
+			_, _, _, _, visual, content, err := page.GetLayoutMetrics().Do(ctx)
+			if err != nil {
+				return err
+			}
+			if opts.fullPage {
+				clip = page.Viewport{Width: content.Width, Height: content.Height}
+			} else {
+				clip = page.Viewport{X: visual.PageX, Y: visual.PageY, Width: visual.ClientWidth, Height: visual.ClientHeight}
+			}
+		}
+		// Fractional clips come out blurred or cut, so align to whole pixels.
+		x, y := math.Floor(clip.X), math.Floor(clip.Y)
+		clip.Width, clip.Height = math.Ceil(clip.Width+clip.X-x), math.Ceil(clip.Height+clip.Y-y)
+		clip.X, clip.Y = x, y
+		if clip.Width <= 0 || clip.Height <= 0 {
+			return fmt.Errorf("nothing to capture, the region is empty")
+		}
+		clip.Scale = fitScale(clip.Width, clip.Height, opts.maxDimension)
+
+		capture := page.CaptureScreenshot().
+			WithFormat(opts.format).
+			WithClip(&clip).
+			WithCaptureBeyondViewport(true).
+			WithFromSurface(true)
+		if opts.format != page.CaptureScreenshotFormatPng {
+			capture = capture.WithQuality(opts.quality)
+		}
+		var err error
+		*buf, err = capture.Do(ctx)
+		return err
+	})
+}
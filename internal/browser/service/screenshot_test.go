@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/emulation"
+	"github.com/chromedp/cdproto/page"
+)
+
+func TestParseScreenshotOptions(t *testing.T) {
+	opts, err := parseScreenshotOptions(map[string]interface{}{
+		"format":        "webp",
+		"quality":       float64(150),
+		"width":         float64(800),
+		"full_page":     false,
+		"max_dimension": float64(1000),
+		"clip":          map[string]interface{}{"x": float64(10), "y": float64(20), "width": float64(300), "height": float64(200)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.format != page.CaptureScreenshotFormatWebp || opts.quality != 100 || opts.width != 800 || opts.height != 0 || opts.fullPage {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+	if opts.clip == nil || opts.clip.X != 10 || opts.clip.Height != 200 {
+		t.Errorf("unexpected clip: %+v", opts.clip)
+	}
+
+	if _, err := parseScreenshotOptions(map[string]interface{}{"format": "gif"}); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+	if _, err := parseScreenshotOptions(map[string]interface{}{"clip": map[string]interface{}{"x": float64(1)}}); err == nil {
+		t.Error("expected an error for an empty clip")
+	}
+}
+
+func TestFitScale(t *testing.T) {
+	tests := []struct {
+		width, height, max, want float64
+	}{
+		{1000, 500, 2000, 1},
+		{4000, 1000, 2000, 0.5},
+		{1000, 8000, 2000, 0.25},
+		{4000, 1000, 0, 1},
+	}
+	for _, tt := range tests {
+		if got := fitScale(tt.width, tt.height, tt.max); got != tt.want {
+			t.Errorf("fitScale(%v, %v, %v) = %v, want %v", tt.width, tt.height, tt.max, got, tt.want)
+		}
+	}
+}
+
+// metricsExecutor records the viewport commands it is sent
+type metricsExecutor struct {
+	calls []string
+}
+
+func (e *metricsExecutor) Execute(ctx context.Context, method string, params, res any) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	call := method
+	if p, ok := params.(*emulation.SetDeviceMetricsOverrideParams); ok {
+		call = fmt.Sprintf("%s %dx%d", method, p.Width, p.Height)
+	}
+	e.calls = append(e.calls, call)
+	return nil
+}
+
+func TestMetricsOverrides(t *testing.T) {
+	exec := &metricsExecutor{}
+	var m metricsOverrides
+	ctx, cancel := context.WithCancel(cdp.WithExecutor(context.Background(), exec))
+	restoreFirst, err := m.push(ctx, emulation.SetDeviceMetricsOverride(100, 100, 1, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	restoreSecond, err := m.push(ctx, emulation.SetDeviceMetricsOverride(200, 200, 1, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Restoring works after the screenshot's context is gone.
+	cancel()
+	restoreFirst()
+	restoreSecond()
+
+	want := []string{
+		emulation.CommandSetDeviceMetricsOverride + " 100x100",
+		emulation.CommandSetDeviceMetricsOverride + " 200x200",
+		emulation.CommandSetDeviceMetricsOverride + " 200x200",
+		emulation.CommandClearDeviceMetricsOverride,
+	}
+	if !slices.Equal(exec.calls, want) {
+		t.Errorf("got %v, want %v", exec.calls, want)
+	}
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/accessibility"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/dom"
+	"github.com/chromedp/chromedp"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// maxSnapshotText caps names and values in a snapshot line
+const maxSnapshotText = 100
+
+// interactiveRoles are the roles that get a ref in a snapshot
+var interactiveRoles = map[string]bool{
+	"button":           true,
+	"checkbox":         true,
+	"combobox":         true,
+	"link":             true,
+	"listbox":          true,
+	"menuitem":         true,
+	"menuitemcheckbox": true,
+	"menuitemradio":    true,
+	"option":           true,
+	"radio":            true,
+	"searchbox":        true,
+	"slider":           true,
+	"spinbutton":       true,
+	"switch":           true,
+	"tab":              true,
+	"textbox":          true,
+	"treeitem":         true,
+}
+
+// skippedRoles are left out of a snapshot, their children move up a level
+var skippedRoles = map[string]bool{
+	"generic":       true,
+	"none":          true,
+	"presentation":  true,
+	"InlineTextBox": true,
+	"LineBreak":     true,
+}
+
+// snapshotStates are the properties shown as node state, in this order
+var snapshotStates = []accessibility.PropertyName{
+	accessibility.PropertyNameLevel,
+	accessibility.PropertyNameChecked,
+	accessibility.PropertyNamePressed,
+	accessibility.PropertyNameSelected,
+	accessibility.PropertyNameExpanded,
+	accessibility.PropertyNameFocused,
+	accessibility.PropertyNameDisabled,
+	accessibility.PropertyNameReadonly,
+	accessibility.PropertyNameRequired,
+}
+
+// refTable maps the refs of a tab's snapshots to DOM nodes. A node keeps its
+// ref across snapshots for as long as it stays in the document.
+type refTable struct {
+	mu    sync.Mutex
+	seq   int
+	nodes map[string]cdp.BackendNodeID
+	refs  map[cdp.BackendNodeID]string
+}
+
+// update starts a new snapshot: ref returns the ref of a node, reusing the one
+// it had before, and refs of nodes not seen again are dropped.
+func (rt *refTable) update(build func(ref func(cdp.BackendNodeID) string)) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	nodes := make(map[string]cdp.BackendNodeID)
+	refs := make(map[cdp.BackendNodeID]string)
+	build(func(id cdp.BackendNodeID) string {
+		ref, ok := rt.refs[id]
+		if !ok {
+			rt.seq++
+			ref = fmt.Sprintf("e%d", rt.seq)
+		}
+		nodes[ref] = id
+		refs[id] = ref
+		return ref
+	})
+	rt.nodes = nodes
+	rt.refs = refs
+}
+
+func (rt *refTable) lookup(ref string) (cdp.BackendNodeID, bool) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	id, ok := rt.nodes[ref]
+	return id, ok
+}
+
+func (bs *BrowserService) registerSnapshotTool() {
+	bs.AddTool(mcp.NewTool(
+		"browser_snapshot",
+		mcp.WithDescription("Get the accessibility tree of the page with role, name, value and state of each node. Interactive nodes carry a ref that browser_click, browser_fill, browser_select and browser_hover accept instead of a selector"),
+		mcp.WithBoolean("interactive",
+			mcp.Description("Only list the interactive nodes"),
+			mcp.DefaultBool(false),
+		),
+		timeoutArg(bs.config.Timeout),
+		tabArg(),
+	), bs.handleSnapshot)
+}
+
+func (bs *BrowserService) handleSnapshot(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	interactive, _ := request.Params.Arguments["interactive"].(bool)
+	t, err := bs.tabOf(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	var (
+		nodes      []*accessibility.Node
+		url, title string
+	)
+	err = bs.run(ctx, t, timeout(request.Params.Arguments, bs.config.Timeout),
+		chromedp.Location(&url),
+		chromedp.Title(&title),
+		accessibility.Enable(),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			nodes, err = accessibility.GetFullAXTree().Do(ctx)
+			return err
+		}),
+	)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to take snapshot: %v", err)), nil
+	}
+
+	var tree string
+	t.refs.update(func(ref func(cdp.BackendNodeID) string) {
+		tree = formatAXTree(nodes, interactive, ref)
+	})
+	return mcp.NewToolResultText(fmt.Sprintf("Page: %s\nTitle: %s\n\n%s", url, title, tree)), nil
+}
+
+// formatAXTree renders an accessibility tree as an indented list, one node per
+// line, calling ref for every interactive node backed by a DOM node.
+func formatAXTree(nodes []*accessibility.Node, interactiveOnly bool, ref func(cdp.BackendNodeID) string) string {
+	if len(nodes) == 0 {
+		return ""
+	}
+	byID := make(map[accessibility.NodeID]*accessibility.Node, len(nodes))
+	for _, n := range nodes {
+		byID[n.NodeID] = n
+	}
+	root := nodes[0]
+	for _, n := range nodes {
+		if n.ParentID == "" {
+			root = n
+			break
+		}
+	}
+
+	var b strings.Builder
+	var walk func(n *accessibility.Node, depth int, parentName string)
+	walk = func(n *accessibility.Node, depth int, parentName string) {
+		role := axString(n.Role)
+		name := axString(n.Name)
+		children := func(depth int, name string) {
+			for _, id := range n.ChildIDs {
+				if c, ok := byID[id]; ok {
+					walk(c, depth, name)
+				}
+			}
+		}
+
+		if n.Ignored || skippedRoles[role] {
+			children(depth, parentName)
+			return
+		}
+		if role == "StaticText" {
+			// Text usually repeats the name of the link or button it is in.
+			if !interactiveOnly && name != "" && name != parentName {
+				fmt.Fprintf(&b, "%s- text %s\n", strings.Repeat("  ", depth), quoteText(name))
+			}
+			return
+		}
+		interactive := isInteractive(role, n.Properties)
+		if interactiveOnly && !interactive {
+			children(depth, parentName)
+			return
+		}
+
+		if role == "RootWebArea" {
+			role = "document"
+		}
+		line := strings.Repeat("  ", depth) + "- " + role
+		if name != "" {
+			line += " " + quoteText(name)
+		}
+		if interactive && n.BackendDOMNodeID != 0 {
+			line += " [ref=" + ref(n.BackendDOMNodeID) + "]"
+		}
+		for _, state := range axStates(n.Properties) {
+			line += " [" + state + "]"
+		}
+		if value := axString(n.Value); value != "" {
+			line += " value=" + quoteText(value)
+		}
+		b.WriteString(line + "\n")
+		children(depth+1, name)
+	}
+	walk(root, 0, "")
+	return b.String()
+}
+
+func isInteractive(role string, props []*accessibility.Property) bool {
+	if interactiveRoles[role] {
+		return true
+	}
+	// contenteditable regions are focusable and editable without a role
+	var focusable, editable bool
+	for _, p := range props {
+		switch p.Name {
+		case accessibility.PropertyNameFocusable:
+			focusable = axString(p.Value) == "true"
+		case accessibility.PropertyNameEditable:
+			editable = axString(p.Value) != ""
+		}
+	}
+	return focusable && editable
+}
+
+// axStates returns the state properties worth showing, e.g. "checked" or
+// "level=2"; false booleans are left out except for expanded.
+func axStates(props []*accessibility.Property) []string {
+	values := make(map[accessibility.PropertyName]string)
+	for _, p := range props {
+		values[p.Name] = axString(p.Value)
+	}
+	var states []string
+	for _, name := range snapshotStates {
+		v, ok := values[name]
+		switch {
+		case !ok || v == "" || v == "false" && name != accessibility.PropertyNameExpanded:
+		case v == "true":
+			states = append(states, string(name))
+		default:
+			states = append(states, string(name)+"="+v)
+		}
+	}
+	return states
+}
+
+// axString returns the value of an accessibility value as text
+func axString(v *accessibility.Value) string {
+	if v == nil || len(v.Value) == 0 {
+		return ""
+	}
+	var val interface{}
+	if err := json.Unmarshal([]byte(v.Value), &val); err != nil {
+		return ""
+	}
+	switch val := val.(type) {
+	case string:
+		return strings.TrimSpace(val)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+// quoteText quotes s for a snapshot line, shortened to maxSnapshotText runes
+func quoteText(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > maxSnapshotText {
+		s = string(r[:maxSnapshotText]) + "…"
+	}
+	return fmt.Sprintf("%q", s)
+}
+
+// elementSelector returns the query for the element a tool acts on, from its
+// ref argument or else its selector argument, and a description for messages.
+// Resolving a ref gives up after d, the timeout of the calling tool.
+func (bs *BrowserService) elementSelector(ctx context.Context, t *tab, args map[string]interface{}, d time.Duration) (interface{}, []chromedp.QueryOption, string, error) {
+	if ref, _ := args["ref"].(string); ref != "" {
+		backendID, ok := t.refs.lookup(ref)
+		if !ok {
+			return nil, nil, "", fmt.Errorf("unknown ref %s, take a new snapshot", ref)
+		}
+		var ids []cdp.NodeID
+		err := bs.run(ctx, t, d, chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			ids, err = dom.PushNodesByBackendIDsToFrontend([]cdp.BackendNodeID{backendID}).Do(ctx)
+			return err
+		}))
+		if err != nil || len(ids) == 0 || ids[0] == 0 {
+			return nil, nil, "", fmt.Errorf("ref %s is no longer in the page, take a new snapshot", ref)
+		}
+		return ids, []chromedp.QueryOption{chromedp.ByNodeID}, "ref " + ref, nil
+	}
+	selector, ok := args["selector"].(string)
+	if !ok || selector == "" {
+		return nil, nil, "", fmt.Errorf("either selector or ref must be given")
+	}
+	return selector, []chromedp.QueryOption{chromedp.ByQuery}, selector, nil
+}
+
+// refArg is the ref argument of the tools acting on a single element
+func refArg() mcp.ToolOption {
+	return mcp.WithString("ref",
+		mcp.Description("The ref of the element from browser_snapshot, instead of a selector"),
+	)
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/chromedp/cdproto/accessibility"
+	"github.com/chromedp/cdproto/cdp"
+)
+
+func axValue(raw string) *accessibility.Value {
+	return &accessibility.Value{Value: []byte(raw)}
+}
+
+func axNode(id, parent, role, name string, backend cdp.BackendNodeID, children ...string) *accessibility.Node {
+	n := &accessibility.Node{
+		NodeID:           accessibility.NodeID(id),
+		ParentID:         accessibility.NodeID(parent),
+		Role:             axValue(`"` + role + `"`),
+		BackendDOMNodeID: backend,
+	}
+	if name != "" {
+		n.Name = axValue(`"` + name + `"`)
+	}
+	for _, c := range children {
+		n.ChildIDs = append(n.ChildIDs, accessibility.NodeID(c))
+	}
+	return n
+}
+
+func TestFormatAXTree(t *testing.T) {
+	heading := axNode("3", "2", "heading", "Welcome", 12, "4")
+	heading.Properties = []*accessibility.Property{{Name: accessibility.PropertyNameLevel, Value: axValue("1")}}
+	email := axNode("7", "2", "textbox", "Email", 16)
+	email.Value = axValue(`"me@example.com"`)
+	email.Properties = []*accessibility.Property{
+		{Name: accessibility.PropertyNameRequired, Value: axValue("true")},
+		{Name: accessibility.PropertyNameDisabled, Value: axValue("false")},
+	}
+	nodes := []*accessibility.Node{
+		axNode("1", "", "RootWebArea", "Login", 10, "2"),
+		axNode("2", "1", "generic", "", 11, "3", "5", "7"),
+		heading,
+		axNode("4", "3", "StaticText", "Welcome", 13),
+		axNode("5", "2", "link", "Sign up", 14, "6"),
+		axNode("6", "5", "StaticText", "Sign up", 15),
+		email,
+	}
+
+	var rt refTable
+	var tree string
+	rt.update(func(ref func(cdp.BackendNodeID) string) {
+		tree = formatAXTree(nodes, false, ref)
+	})
+	want := strings.Join([]string{
+		`- document "Login"`,
+		`  - heading "Welcome" [level=1]`,
+		`  - link "Sign up" [ref=e1]`,
+		`  - textbox "Email" [ref=e2] [required] value="me@example.com"`,
+		``,
+	}, "\n")
+	if tree != want {
+		t.Errorf("unexpected tree:\n%s\nwant:\n%s", tree, want)
+	}
+	if id, ok := rt.lookup("e2"); !ok || id != 16 {
+		t.Errorf("expected e2 to map to node 16, got %d %v", id, ok)
+	}
+
+	// Refs survive a new snapshot, nodes that are gone lose theirs.
+	rt.update(func(ref func(cdp.BackendNodeID) string) {
+		tree = formatAXTree([]*accessibility.Node{nodes[0], nodes[1], email}, true, ref)
+	})
+	if tree != "- textbox \"Email\" [ref=e2] [required] value=\"me@example.com\"\n" {
+		t.Errorf("unexpected interactive tree:\n%s", tree)
+	}
+	if _, ok := rt.lookup("e1"); ok {
+		t.Error("expected e1 to be dropped")
+	}
+}
@@ -0,0 +1,368 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/chromedp/cdproto/browser"
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/cdproto/target"
+	"github.com/chromedp/chromedp"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// tab is a page target the tools can act on. Tabs are numbered in the order
+// they appear, either opened through browser_tab_new or by the page itself.
+type tab struct {
+	id       string
+	seq      int
+	targetID target.ID
+	opener   string
+	ctx      context.Context
+	// cancel closes the tab, it is nil for the first tab whose context owns
+	// the whole browser
+	cancel context.CancelFunc
+	refs   refTable
+	rec    *recorder
+	// metrics are the viewport overrides screenshots put in place
+	metrics metricsOverrides
+}
+
+// tabInfo is a tab as reported by browser_tab_list
+type tabInfo struct {
+	ID      string `json:"id"`
+	URL     string `json:"url"`
+	Title   string `json:"title"`
+	Opener  string `json:"opener,omitempty"`
+	Current bool   `json:"current,omitempty"`
+}
+
+// tabArg is the optional tab argument every page tool accepts
+func tabArg() mcp.ToolOption {
+	return mcp.WithString("tab",
+		mcp.Description("Id of the tab to act on, default: the current tab"),
+	)
+}
+
+func (bs *BrowserService) registerTabTools() {
+	bs.AddTool(mcp.NewTool(
+		"browser_tab_new",
+		mcp.WithDescription("Open a new tab and make it the current tab"),
+		mcp.WithString("url",
+			mcp.Description("The URL to open in the new tab"),
+		),
+		waitUntilArg(),
+		timeoutArg(bs.config.URLTimeout),
+	), bs.handleTabNew)
+
+	bs.AddTool(mcp.NewTool(
+		"browser_tab_list",
+		mcp.WithDescription("List the open tabs with id, url and title, including tabs opened by pages"),
+	), bs.handleTabList)
+
+	bs.AddTool(mcp.NewTool(
+		"browser_tab_switch",
+		mcp.WithDescription("Make a tab the current tab and bring it to the front"),
+		mcp.WithString("tab",
+			mcp.Description("Id of the tab to switch to"),
+			mcp.Required(),
+		),
+	), bs.handleTabSwitch)
+
+	bs.AddTool(mcp.NewTool(
+		"browser_tab_close",
+		mcp.WithDescription("Close a tab"),
+		mcp.WithString("tab",
+			mcp.Description("Id of the tab to close, default: the current tab"),
+		),
+	), bs.handleTabClose)
+}
+
+// startLocked launches the browser on first use, registers its initial tab
+// and starts tracking the targets pages open. bs.mu must be held.
+func (bs *BrowserService) startLocked() error {
+	if bs.started {
+		return nil
+	}
+	if err := chromedp.Run(bs.ctx); err != nil {
+		return fmt.Errorf("failed to start browser: %v", err)
+	}
+	bs.started = true
+	first := bs.addTabLocked(bs.ctx, nil, chromedp.FromContext(bs.ctx).Target.TargetID, "")
+	bs.current = first.id
+	chromedp.ListenBrowser(bs.ctx, bs.onBrowserEvent)
+	c := chromedp.FromContext(bs.ctx)
+	if err := bs.downloads.behavior().Do(cdp.WithExecutor(bs.ctx, c.Browser)); err != nil {
+		slog.Warn("Failed to set up downloads", "dir", bs.downloads.dir, "error", err)
+	}
+	return nil
+}
+
+// onBrowserEvent runs on the browser's event loop and must not block
+func (bs *BrowserService) onBrowserEvent(ev any) {
+	switch e := ev.(type) {
+	case *target.EventTargetCreated:
+		if e.TargetInfo.Type == "page" && e.TargetInfo.OpenerID != "" {
+			go bs.trackTarget(e.TargetInfo)
+		}
+	case *target.EventTargetDestroyed:
+		go bs.forgetTarget(e.TargetID)
+	case *browser.EventDownloadWillBegin, *browser.EventDownloadProgress:
+		bs.downloads.handle(ev)
+	}
+}
+
+// trackTarget adds a tab for a target opened by a page, e.g. via window.open
+// or a link with target=_blank
+func (bs *BrowserService) trackTarget(info *target.Info) {
+	bs.mu.Lock()
+	browserCtx := bs.ctx
+	bs.mu.Unlock()
+
+	// Attach right away, the first run binds the tab to its context and must
+	// not be one that times out.
+	ctx, cancel := chromedp.NewContext(browserCtx, chromedp.WithTargetID(info.TargetID))
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		slog.Warn("Failed to attach to tab", "url", info.URL, "error", err)
+		return
+	}
+
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if bs.ctx != browserCtx {
+		// The browser was restarted meanwhile.
+		cancel()
+		return
+	}
+	var opener string
+	if t := bs.tabByTarget(info.OpenerID); t != nil {
+		opener = t.id
+	}
+	bs.addTabLocked(ctx, cancel, info.TargetID, opener)
+}
+
+// forgetTarget drops the tab of a target that went away
+func (bs *BrowserService) forgetTarget(id target.ID) {
+	bs.mu.Lock()
+	t := bs.tabByTarget(id)
+	if t != nil {
+		bs.removeTabLocked(t)
+	}
+	bs.mu.Unlock()
+	if t != nil && t.cancel != nil {
+		t.cancel()
+	}
+}
+
+func (bs *BrowserService) addTabLocked(ctx context.Context, cancel context.CancelFunc, id target.ID, opener string) *tab {
+	bs.tabSeq++
+	t := &tab{
+		id:       fmt.Sprintf("t%d", bs.tabSeq),
+		seq:      bs.tabSeq,
+		targetID: id,
+		opener:   opener,
+		ctx:      ctx,
+		cancel:   cancel,
+		rec:      newRecorder(),
+	}
+	chromedp.ListenTarget(ctx, t.rec.handle)
+	bs.tabs[t.id] = t
+	return t
+}
+
+// removeTabLocked forgets t and picks the most recent remaining tab as the
+// current one if t was current
+func (bs *BrowserService) removeTabLocked(t *tab) {
+	delete(bs.tabs, t.id)
+	if bs.current != t.id {
+		return
+	}
+	bs.current = ""
+	for _, other := range bs.sortedTabsLocked() {
+		bs.current = other.id
+	}
+}
+
+func (bs *BrowserService) tabByTarget(id target.ID) *tab {
+	for _, t := range bs.tabs {
+		if t.targetID == id {
+			return t
+		}
+	}
+	return nil
+}
+
+func (bs *BrowserService) sortedTabsLocked() []*tab {
+	tabs := make([]*tab, 0, len(bs.tabs))
+	for _, t := range bs.tabs {
+		tabs = append(tabs, t)
+	}
+	sort.Slice(tabs, func(i, j int) bool { return tabs[i].seq < tabs[j].seq })
+	return tabs
+}
+
+// newTabLocked opens a blank tab. bs.mu must be held.
+func (bs *BrowserService) newTabLocked() (*tab, error) {
+	ctx, cancel := chromedp.NewContext(bs.ctx)
+	if err := chromedp.Run(ctx); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to open tab: %v", err)
+	}
+	return bs.addTabLocked(ctx, cancel, chromedp.FromContext(ctx).Target.TargetID, ""), nil
+}
+
+// openTab opens a blank tab and makes it the current one
+func (bs *BrowserService) openTab() (*tab, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if err := bs.startLocked(); err != nil {
+		return nil, err
+	}
+	t, err := bs.newTabLocked()
+	if err != nil {
+		return nil, err
+	}
+	bs.current = t.id
+	return t, nil
+}
+
+// tab returns the tab with the given id, or the current tab when id is empty.
+// A new tab is opened when the last one was closed.
+func (bs *BrowserService) tab(id string) (*tab, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if err := bs.startLocked(); err != nil {
+		return nil, err
+	}
+	if id != "" {
+		t, ok := bs.tabs[id]
+		if !ok {
+			return nil, fmt.Errorf("unknown tab: %s", id)
+		}
+		return t, nil
+	}
+	if t, ok := bs.tabs[bs.current]; ok {
+		return t, nil
+	}
+	t, err := bs.newTabLocked()
+	if err != nil {
+		return nil, err
+	}
+	bs.current = t.id
+	return t, nil
+}
+
+// tabOf returns the tab named by the request's tab argument
+func (bs *BrowserService) tabOf(request mcp.CallToolRequest) (*tab, error) {
+	id, _ := request.Params.Arguments["tab"].(string)
+	return bs.tab(id)
+}
+
+func (bs *BrowserService) handleTabNew(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	url, _ := request.Params.Arguments["url"].(string)
+
+	t, err := bs.openTab()
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if url == "" {
+		return mcp.NewToolResultText(fmt.Sprintf("Opened tab %s", t.id)), nil
+	}
+	navigate, err := navigateAction(url, request.Params.Arguments)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := bs.run(ctx, t, timeout(request.Params.Arguments, bs.config.URLTimeout), navigate); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Opened tab %s but failed to navigate to %s: %v", t.id, url, err)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Opened tab %s with %s", t.id, url)), nil
+}
+
+func (bs *BrowserService) handleTabList(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if err := bs.startLocked(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	targets, err := chromedp.Targets(bs.ctx)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to list tabs: %v", err)), nil
+	}
+	infos := make(map[target.ID]*target.Info, len(targets))
+	for _, info := range targets {
+		infos[info.TargetID] = info
+	}
+
+	list := []tabInfo{}
+	for _, t := range bs.sortedTabsLocked() {
+		ti := tabInfo{ID: t.id, Opener: t.opener, Current: t.id == bs.current}
+		if info, ok := infos[t.targetID]; ok {
+			ti.URL = info.URL
+			ti.Title = info.Title
+		}
+		list = append(list, ti)
+	}
+	payload, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(string(payload)), nil
+}
+
+func (bs *BrowserService) handleTabSwitch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, ok := request.Params.Arguments["tab"].(string)
+	if !ok || id == "" {
+		return mcp.NewToolResultError("tab must be a string"), nil
+	}
+	t, err := bs.tab(id)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if err := bs.run(ctx, t, time.Duration(bs.config.Timeout)*time.Second, page.BringToFront()); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to switch to tab %s: %v", id, err)), nil
+	}
+	bs.mu.Lock()
+	bs.current = t.id
+	bs.mu.Unlock()
+	return mcp.NewToolResultText(fmt.Sprintf("Switched to tab %s", id)), nil
+}
+
+func (bs *BrowserService) handleTabClose(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, _ := request.Params.Arguments["tab"].(string)
+	t, err := bs.tab(id)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	if t.cancel != nil {
+		t.cancel()
+	} else {
+		// The first tab's context owns the browser, so only its target is closed.
+		bs.mu.Lock()
+		browserCtx := bs.ctx
+		bs.mu.Unlock()
+		c := chromedp.FromContext(browserCtx)
+		if err := target.CloseTarget(t.targetID).Do(cdp.WithExecutor(browserCtx, c.Browser)); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to close tab %s: %v", t.id, err)), nil
+		}
+	}
+
+	// The tab is only forgotten once it is closed, forgetTarget may have
+	// done so already.
+	bs.mu.Lock()
+	if bs.tabs[t.id] == t {
+		bs.removeTabLocked(t)
+	}
+	current := bs.current
+	bs.mu.Unlock()
+	if current == "" {
+		return mcp.NewToolResultText(fmt.Sprintf("Closed tab %s, no tabs are open", t.id)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Closed tab %s, current tab is %s", t.id, current)), nil
+}
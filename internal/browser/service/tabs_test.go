@@ -0,0 +1,33 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRemoveTab(t *testing.T) {
+	bs := &BrowserService{tabs: make(map[string]*tab)}
+	for _, seq := range []int{3, 1, 2} {
+		id := fmt.Sprintf("t%d", seq)
+		bs.tabs[id] = &tab{id: id, seq: seq}
+	}
+	if got := bs.sortedTabsLocked(); len(got) != 3 || got[0].id != "t1" || got[1].id != "t2" || got[2].id != "t3" {
+		t.Fatalf("expected tabs in the order they appeared, got %v", got)
+	}
+
+	// Closing a tab that is not current keeps the current one.
+	bs.current = "t1"
+	bs.removeTabLocked(bs.tabs["t2"])
+	if bs.current != "t1" || len(bs.tabs) != 2 {
+		t.Errorf("expected t1 to stay current, got %q", bs.current)
+	}
+	// Closing the current tab picks the most recent one left.
+	bs.removeTabLocked(bs.tabs["t1"])
+	if bs.current != "t3" {
+		t.Errorf("expected t3 to become current, got %q", bs.current)
+	}
+	bs.removeTabLocked(bs.tabs["t3"])
+	if bs.current != "" || len(bs.sortedTabsLocked()) != 0 {
+		t.Errorf("expected no current tab, got %q", bs.current)
+	}
+}
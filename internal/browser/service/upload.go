@@ -0,0 +1,103 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func (bs *BrowserService) registerUploadTool() {
+	bs.AddTool(mcp.NewTool(
+		"browser_upload",
+		mcp.WithDescription(fmt.Sprintf("Set the files of a file input, e.g. input[type=file]. Files must be in one of the upload directories: %s", strings.Join(bs.config.UploadDirs(), ", "))),
+		mcp.WithString("selector",
+			mcp.Description("The CSS selector of the file input"),
+		),
+		refArg(),
+		mcp.WithArray("paths",
+			mcp.Required(),
+			mcp.Description("The files to upload, relative paths are resolved against the first upload directory"),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+		),
+		timeoutArg(bs.config.CSSTimeout),
+		tabArg(),
+	), bs.handleUpload)
+}
+
+func (bs *BrowserService) handleUpload(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	raw, _ := args["paths"].([]interface{})
+	if len(raw) == 0 {
+		return mcp.NewToolResultError("paths must be a non-empty array of strings"), nil
+	}
+	files := make([]string, 0, len(raw))
+	for _, v := range raw {
+		path, ok := v.(string)
+		if !ok || path == "" {
+			return mcp.NewToolResultError("paths must be a non-empty array of strings"), nil
+		}
+		resolved, err := resolveUploadPath(path, bs.config.UploadDirs())
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		files = append(files, resolved)
+	}
+
+	t, err := bs.tabOf(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	sel, opts, selector, err := bs.elementSelector(ctx, t, args, timeout(args, bs.config.CSSTimeout))
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	err = bs.run(ctx, t, timeout(args, bs.config.CSSTimeout), chromedp.SetUploadFiles(sel, files, opts...))
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to upload files to %s: %v", selector, err)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Set %d file(s) on %s: %s", len(files), selector, strings.Join(files, ", "))), nil
+}
+
+// resolveUploadPath returns the real path of a file inside one of roots.
+// Symlinks are followed first, so a link cannot lead out of the roots.
+func resolveUploadPath(path string, roots []string) (string, error) {
+	if len(roots) == 0 {
+		return "", fmt.Errorf("no upload directories are configured")
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(roots[0], path)
+	}
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot upload %s: %v", path, err)
+	}
+	if real, err = filepath.Abs(real); err != nil {
+		return "", err
+	}
+	info, err := os.Stat(real)
+	if err != nil {
+		return "", fmt.Errorf("cannot upload %s: %v", path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return "", fmt.Errorf("cannot upload %s: not a regular file", path)
+	}
+	for _, root := range roots {
+		root, err := filepath.EvalSymlinks(root)
+		if err != nil {
+			continue
+		}
+		if root, err = filepath.Abs(root); err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(root, real)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return real, nil
+		}
+	}
+	return "", fmt.Errorf("cannot upload %s: it is outside the upload directories", path)
+}
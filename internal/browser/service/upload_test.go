@@ -0,0 +1,40 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveUploadPath(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "report.csv"), []byte("a,b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := resolveUploadPath("report.csv", []string{root})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want, _ := filepath.EvalSymlinks(filepath.Join(root, "report.csv")); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if _, err := resolveUploadPath(filepath.Join(outside, "secret.txt"), []string{t.TempDir(), root}); err == nil {
+		t.Error("expected a file outside the roots to be rejected")
+	}
+	for _, path := range []string{"link.txt", "../" + filepath.Base(outside) + "/secret.txt", "dir", "missing.txt"} {
+		if _, err := resolveUploadPath(path, []string{root}); err == nil {
+			t.Errorf("expected %s to be rejected", path)
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// pollInterval is how often browser_wait checks its condition
+	pollInterval = 100 * time.Millisecond
+	// networkQuietPeriod is how long no request may be in flight for the
+	// network to count as idle
+	networkQuietPeriod = 500 * time.Millisecond
+)
+
+// Element states browser_wait can wait for
+const (
+	stateAttached = "attached"
+	stateVisible  = "visible"
+	stateHidden   = "hidden"
+)
+
+// elementStateJS checks the state of the first element matching a selector,
+// hidden also holds when there is no such element
+const elementStateJS = `((sel, state) => {
+	const el = document.querySelector(sel);
+	if (state === "attached") return el !== null;
+	const visible = !!el && el.getClientRects().length > 0 && getComputedStyle(el).visibility !== "hidden";
+	return state === "visible" ? visible : !visible;
+})(%s, %s)`
+
+// timeoutArg is the timeout argument of a tool, def is its default in seconds
+func timeoutArg(def int) mcp.ToolOption {
+	return mcp.WithNumber("timeout",
+		mcp.Description(fmt.Sprintf("Seconds to wait before giving up, default: %d", def)),
+	)
+}
+
+// timeout returns the timeout argument of a request, or def seconds
+func timeout(args map[string]interface{}, def int) time.Duration {
+	if v, ok := args["timeout"].(float64); ok && v > 0 {
+		return time.Duration(v * float64(time.Second))
+	}
+	return time.Duration(def) * time.Second
+}
+
+// run runs actions in a tab, giving up after d or when ctx, the request
+// context, is done. The tab itself stays open either way.
+func (bs *BrowserService) run(ctx context.Context, t *tab, d time.Duration, actions ...chromedp.Action) error {
+	runCtx, cancel := context.WithTimeout(t.ctx, d)
+	defer cancel()
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	err := chromedp.Run(runCtx, actions...)
+	if err != nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %v", d)
+	}
+	return err
+}
+
+func (bs *BrowserService) registerWaitTool() {
+	bs.AddTool(mcp.NewTool(
+		"browser_wait",
+		mcp.WithDescription("Wait until a condition holds on the page: an element state, a URL, a text, network idle or a JavaScript predicate. Give exactly one condition"),
+		mcp.WithString("selector",
+			mcp.Description("The CSS selector of the element to wait for"),
+		),
+		mcp.WithString("state",
+			mcp.Description("The element state to wait for"),
+			mcp.DefaultString(stateVisible),
+			mcp.Enum(stateAttached, stateVisible, stateHidden),
+		),
+		mcp.WithString("url",
+			mcp.Description("Regular expression the page URL has to match"),
+		),
+		mcp.WithString("text",
+			mcp.Description("Text that has to appear on the page"),
+		),
+		mcp.WithBoolean("network_idle",
+			mcp.Description("Wait until no network request has been in flight for half a second"),
+		),
+		mcp.WithString("function",
+			mcp.Description("JavaScript expression that has to become truthy, e.g. window.appReady === true"),
+		),
+		timeoutArg(bs.config.CSSTimeout),
+		tabArg(),
+	), bs.handleWait)
+}
+
+func (bs *BrowserService) handleWait(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	selector, _ := args["selector"].(string)
+	urlPattern, _ := args["url"].(string)
+	text, _ := args["text"].(string)
+	networkIdle, _ := args["network_idle"].(bool)
+	function, _ := args["function"].(string)
+
+	var (
+		action      chromedp.Action
+		description string
+		count       int
+	)
+	if selector != "" {
+		count++
+		state, _ := args["state"].(string)
+		if state == "" {
+			state = stateVisible
+		}
+		if state != stateAttached && state != stateVisible && state != stateHidden {
+			return mcp.NewToolResultError(fmt.Sprintf("unknown state: %s", state)), nil
+		}
+		action = pollJS(fmt.Sprintf(elementStateJS, jsString(selector), jsString(state)))
+		description = fmt.Sprintf("%s to be %s", selector, state)
+	}
+	if urlPattern != "" {
+		count++
+		re, err := regexp.Compile(urlPattern)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("invalid url pattern: %v", err)), nil
+		}
+		action = pollURL(re)
+		description = fmt.Sprintf("the URL to match %s", urlPattern)
+	}
+	if text != "" {
+		count++
+		action = pollJS(fmt.Sprintf(`!!document.body && document.body.innerText.includes(%s)`, jsString(text)))
+		description = fmt.Sprintf("text %q", text)
+	}
+	if networkIdle {
+		count++
+		action = waitNetworkIdle()
+		description = "the network to be idle"
+	}
+	if function != "" {
+		count++
+		action = pollJS("!!(" + function + ")")
+		description = "the function to be truthy"
+	}
+	if count != 1 {
+		return mcp.NewToolResultError("give exactly one of selector, url, text, network_idle and function"), nil
+	}
+
+	t, err := bs.tabOf(request)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	start := time.Now()
+	if err := bs.run(ctx, t, timeout(args, bs.config.CSSTimeout), action); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed waiting for %s: %v", description, err)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Waited %v for %s", time.Since(start).Round(time.Millisecond), description)), nil
+}
+
+// jsString quotes s as a JavaScript string literal
+func jsString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// poll runs check every pollInterval until it reports true. Errors, e.g.
+// while a navigation replaces the document, count as false; the last one is
+// returned when the context ends.
+func poll(check func(ctx context.Context) (bool, error)) chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var lastErr error
+		for {
+			ok, err := check(ctx)
+			if ok {
+				return nil
+			}
+			if err != nil {
+				lastErr = err
+			}
+			select {
+			case <-ctx.Done():
+				if lastErr != nil {
+					return fmt.Errorf("%w, last error: %v", ctx.Err(), lastErr)
+				}
+				return ctx.Err()
+			case <-time.After(pollInterval):
+			}
+		}
+	})
+}
+
+func pollJS(expression string) chromedp.Action {
+	return poll(func(ctx context.Context) (bool, error) {
+		var ok bool
+		err := chromedp.Evaluate(expression, &ok).Do(ctx)
+		return ok, err
+	})
+}
+
+func pollURL(re *regexp.Regexp) chromedp.Action {
+	return poll(func(ctx context.Context) (bool, error) {
+		var url string
+		if err := chromedp.Location(&url).Do(ctx); err != nil {
+			return false, err
+		}
+		return re.MatchString(url), nil
+	})
+}
+
+// waitNetworkIdle waits until no request has been in flight for
+// networkQuietPeriod. Only requests started after the wait began are seen.
+func waitNetworkIdle() chromedp.Action {
+	return chromedp.ActionFunc(func(ctx context.Context) error {
+		var mu sync.Mutex
+		inflight := make(map[network.RequestID]bool)
+		changed := make(chan struct{}, 1)
+
+		lctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		chromedp.ListenTarget(lctx, func(ev any) {
+			mu.Lock()
+			defer mu.Unlock()
+			switch e := ev.(type) {
+			case *network.EventRequestWillBeSent:
+				inflight[e.RequestID] = true
+			case *network.EventLoadingFinished:
+				delete(inflight, e.RequestID)
+			case *network.EventLoadingFailed:
+				delete(inflight, e.RequestID)
+			default:
+				return
+			}
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		})
+
+		for {
+			mu.Lock()
+			busy := len(inflight) > 0
+			mu.Unlock()
+			quiet := time.After(networkQuietPeriod)
+			if busy {
+				quiet = nil
+			}
+			select {
+			case <-quiet:
+				return nil
+			case <-changed:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	})
+}
+
+// waitUntilArg is the wait_until argument of the navigating tools
+func waitUntilArg() mcp.ToolOption {
+	return mcp.WithString("wait_until",
+		mcp.Description("When navigation counts as done"),
+		mcp.DefaultString("load"),
+		mcp.Enum("load", "domcontentloaded", "networkidle"),
+	)
+}
+
+// navigateAction returns the navigation for the wait_until argument
+func navigateAction(url string, args map[string]interface{}) (chromedp.Action, error) {
+	waitUntil, _ := args["wait_until"].(string)
+	switch strings.ToLower(waitUntil) {
+	case "", "load":
+		return chromedp.Navigate(url), nil
+	case "domcontentloaded":
+		return NavigateUntil(url, LifecycleDOMContentLoaded), nil
+	case "networkidle":
+		return NavigateUntil(url, LifecycleNetworkIdle), nil
+	default:
+		return nil, fmt.Errorf("unknown wait_until: %s", waitUntil)
+	}
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeout(t *testing.T) {
+	if got := timeout(map[string]interface{}{}, 30); got != 30*time.Second {
+		t.Errorf("expected the default, got %v", got)
+	}
+	if got := timeout(map[string]interface{}{"timeout": 1.5}, 30); got != 1500*time.Millisecond {
+		t.Errorf("expected 1.5s, got %v", got)
+	}
+	if got := timeout(map[string]interface{}{"timeout": float64(-1)}, 30); got != 30*time.Second {
+		t.Errorf("expected the default for a negative timeout, got %v", got)
+	}
+}
+
+func TestNavigateAction(t *testing.T) {
+	for _, waitUntil := range []string{"", "load", "domcontentloaded", "networkidle", "NetworkIdle"} {
+		if _, err := navigateAction("https://example.com", map[string]interface{}{"wait_until": waitUntil}); err != nil {
+			t.Errorf("wait_until %q: %v", waitUntil, err)
+		}
+	}
+	if _, err := navigateAction("https://example.com", map[string]interface{}{"wait_until": "commit"}); err == nil {
+		t.Error("expected an error for an unknown wait_until")
+	}
+}
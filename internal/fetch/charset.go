@@ -0,0 +1,55 @@
+package fetch
+
+import (
+	"bytes"
+	"io"
+	"unicode/utf8"
+
+	"github.com/gogs/chardet"
+	"golang.org/x/net/html/charset"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const utf8BOM = "\ufeff"
+
+// detectEncoding picks the character encoding of a text or HTML body. It honours
+// a byte order mark, the Content-Type charset parameter and an HTML meta charset
+// declaration, in that order, and sniffs the bytes when none of them is present.
+func detectEncoding(body []byte, contentType string) (encoding.Encoding, string) {
+	enc, name, certain := charset.DetermineEncoding(body, contentType)
+	if certain {
+		return enc, name
+	}
+	// DetermineEncoding only looks at the first 1024 bytes, so check the whole
+	// body before trusting a meta declaration or its windows-1252 default.
+	if utf8.Valid(body) {
+		return unicode.UTF8, "utf-8"
+	}
+	if name != "windows-1252" {
+		return enc, name
+	}
+
+	// windows-1252 is also the fallback when nothing is declared, which garbles
+	// CJK pages, so ask a statistical detector instead.
+	result, err := chardet.NewHtmlDetector().DetectBest(body)
+	if err == nil && result.Charset != "" {
+		if sniffed, sniffedName := charset.Lookup(result.Charset); sniffed != nil {
+			return sniffed, sniffedName
+		}
+	}
+	return enc, name
+}
+
+// decodeBody transcodes a text or HTML body to UTF-8 and strips any byte order mark
+func decodeBody(body []byte, contentType string) ([]byte, error) {
+	enc, _ := detectEncoding(body, contentType)
+	if enc != encoding.Nop && enc != unicode.UTF8 {
+		decoded, err := io.ReadAll(enc.NewDecoder().Reader(bytes.NewReader(body)))
+		if err != nil {
+			return nil, err
+		}
+		body = decoded
+	}
+	return bytes.TrimPrefix(body, []byte(utf8BOM)), nil
+}
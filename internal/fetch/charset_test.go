@@ -0,0 +1,46 @@
+package fetch
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+)
+
+func encodeString(t *testing.T, enc encoding.Encoding, s string) []byte {
+	t.Helper()
+	out, err := enc.NewEncoder().String(s)
+	if err != nil {
+		t.Fatalf("failed to encode test input: %v", err)
+	}
+	return []byte(out)
+}
+
+func TestDecodeBody(t *testing.T) {
+	chinese := "<html><body><p>这是一个用于测试字符集检测的中文网页，包含足够多的汉字以便进行统计分析。我们希望正确地识别编码。</p></body></html>"
+	japaneseText := `<html><head><meta charset="shift_jis"></head><body><p>日本語のページです</p></body></html>`
+
+	cases := []struct {
+		name        string
+		body        []byte
+		contentType string
+		want        string
+	}{
+		{"header charset", encodeString(t, simplifiedchinese.GBK, chinese), "text/html; charset=gbk", chinese},
+		{"meta charset", encodeString(t, japanese.ShiftJIS, japaneseText), "text/html", japaneseText},
+		{"sniffed", encodeString(t, simplifiedchinese.GB18030, chinese), "text/html", chinese},
+		{"utf-8 bom", []byte("\xef\xbb\xbfhello"), "text/plain", "hello"},
+		{"late utf-8", []byte(strings.Repeat("a", 2048) + "中文"), "text/plain", strings.Repeat("a", 2048) + "中文"},
+	}
+	for _, c := range cases {
+		got, err := decodeBody(c.body, c.contentType)
+		if err != nil {
+			t.Fatalf("%s: decodeBody failed: %v", c.name, err)
+		}
+		if string(got) != c.want {
+			t.Errorf("%s: decodeBody = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dyike/MonoMCPHub/pkg/httpclient"
+)
+
+// Credential is a named set of secrets that can be attached to outgoing
+// requests without exposing them to the model. Values may reference
+// environment variables, e.g. "${GITHUB_TOKEN}".
+type Credential struct {
+	// Hosts lists where the secrets may be sent: host names, "*.example.com"
+	// for the subdomains of a domain, or URL prefixes such as
+	// "https://api.example.com/v1/". It is required.
+	Hosts       []string          `json:"hosts"`
+	Headers     map[string]string `json:"headers"`
+	BearerToken string            `json:"bearer_token"`
+	Username    string            `json:"username"`
+	Password    string            `json:"password"`
+}
+
+// Allows reports whether the credential may be sent to u
+func (c *Credential) Allows(u *url.URL) bool {
+	host := strings.ToLower(u.Hostname())
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	for _, h := range c.Hosts {
+		switch {
+		case strings.Contains(h, "://"):
+			p, err := url.Parse(h)
+			if err != nil || !strings.EqualFold(p.Scheme, u.Scheme) || !strings.EqualFold(p.Host, u.Host) {
+				continue
+			}
+			// A path prefix only matches whole segments.
+			if path == p.Path || strings.HasPrefix(path, strings.TrimSuffix(p.Path, "/")+"/") {
+				return true
+			}
+		case strings.HasPrefix(h, "*."):
+			if strings.HasSuffix(host, strings.ToLower(h[1:])) {
+				return true
+			}
+		case strings.EqualFold(host, h):
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Credential) expandEnv() {
+	for k, v := range c.Headers {
+		c.Headers[k] = os.ExpandEnv(v)
+	}
+	c.BearerToken = os.ExpandEnv(c.BearerToken)
+	c.Username = os.ExpandEnv(c.Username)
+	c.Password = os.ExpandEnv(c.Password)
+}
+
+// SearchConfig selects the web_search backend. The tool is only offered when
+// a provider is configured.
+type SearchConfig struct {
+	// Provider names the backend, currently only "searxng"
+	Provider string `json:"provider"`
+	// URL is the base URL of the search instance, e.g. http://localhost:8888
+	URL string `json:"url"`
+	// Engines and Categories are passed through to providers that support them
+	Engines    []string `json:"engines"`
+	Categories []string `json:"categories"`
+}
+
+// Profile is a named identity for fetches: credentials sent with every
+// request plus a cookie jar that is persisted under DataPath. It can only be
+// used for its Hosts.
+type Profile struct {
+	Credential
+
+	// ChromeUserDataDir seeds a new jar with the cookies of a Chrome user data
+	// directory, such as the one of the browser service.
+	ChromeUserDataDir string `json:"chrome_user_data_dir"`
+}
+
+type FetchConfig struct {
+	UserAgent      string `json:"user_agent"`
+	Timeout        int    `json:"timeout"`
+	ConnectTimeout int    `json:"connect_timeout"`
+	ReadTimeout    int    `json:"read_timeout"`
+	MaxBodySize    int64  `json:"max_body_size"`
+	MaxRedirects   int    `json:"max_redirects"`
+
+	// AllowPrivateNetworks disables the check that keeps requests away from
+	// loopback, private and link-local addresses.
+	AllowPrivateNetworks bool `json:"allow_private_networks"`
+	// AllowedHosts lists host names, IPs or CIDR ranges that may be reached
+	// even though they are internal.
+	AllowedHosts []string `json:"allowed_hosts"`
+
+	Credentials map[string]Credential `json:"credentials"`
+	Profiles    map[string]Profile    `json:"profiles"`
+
+	Search SearchConfig `json:"search"`
+
+	// HTTP overrides the process-wide proxy and CA settings for fetches.
+	// Behind a proxy, host names are resolved locally to be checked against
+	// private networks, a proxy that resolves them differently is not caught.
+	HTTP *httpclient.Config `json:"http"`
+
+	// DataPath is where state such as feed positions is persisted
+	DataPath string `json:"data_path"`
+}
+
+func NewFetchConfig() *FetchConfig {
+	return &FetchConfig{
+		UserAgent:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_4) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/85.0.4183.83 Safari/537.36,gzip(gfe)",
+		Timeout:        30,
+		ConnectTimeout: 10,
+		ReadTimeout:    20,
+		MaxBodySize:    10 << 20,
+		MaxRedirects:   10,
+		Credentials:    make(map[string]Credential),
+		Profiles:       make(map[string]Profile),
+		DataPath:       defaultDataPath(),
+	}
+}
+
+func defaultDataPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "fetch_mcp")
+	}
+	return filepath.Join(home, ".fetch_mcp")
+}
+
+// Load reads configuration from a JSON file on top of the defaults.
+// An empty path returns the defaults.
+func Load(path string) (*FetchConfig, error) {
+	cfg := NewFetchConfig()
+	if path == "" {
+		return cfg, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %v", err)
+	}
+
+	for name, cred := range cfg.Credentials {
+		if len(cred.Hosts) == 0 {
+			return nil, fmt.Errorf("credential %s needs hosts it may be sent to", name)
+		}
+		cred.expandEnv()
+		cfg.Credentials[name] = cred
+	}
+	if _, err := httpclient.NewTransport(httpclient.Resolve(cfg.HTTP)); err != nil {
+		return nil, fmt.Errorf("invalid http settings: %v", err)
+	}
+	cfg.Search.URL = os.ExpandEnv(cfg.Search.URL)
+	for name, profile := range cfg.Profiles {
+		if len(profile.Hosts) == 0 {
+			return nil, fmt.Errorf("fetch profile %s needs hosts it may be used for", name)
+		}
+		profile.expandEnv()
+		profile.ChromeUserDataDir = os.ExpandEnv(profile.ChromeUserDataDir)
+		cfg.Profiles[name] = profile
+	}
+	return cfg, nil
+}
@@ -0,0 +1,228 @@
+package fetch
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/ledongthuc/pdf"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/ohler55/ojg/jp"
+)
+
+const (
+	// errorSnippetSize is the number of body bytes included in HTTP error results
+	errorSnippetSize = 512
+)
+
+// fetchOptions holds the fetch_url arguments that shape how a response is rendered
+type fetchOptions struct {
+	AsHTML   bool
+	JSONPath string
+
+	// Metadata prepends a header with the page metadata to Markdown output,
+	// resolving its links against BaseURL.
+	Metadata bool
+	BaseURL  *url.URL
+}
+
+// contentKind is the coarse content class a response is dispatched on
+type contentKind int
+
+const (
+	kindHTML contentKind = iota
+	kindPDF
+	kindJSON
+	kindText
+	kindImage
+	kindUnsupported
+)
+
+// detectContentKind classifies a response from its Content-Type header, falling
+// back to sniffing the body when the header is missing or generic.
+func detectContentKind(contentType string, body []byte) (contentKind, string) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType == "" || mediaType == "application/octet-stream" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+	}
+
+	switch {
+	case mediaType == "text/html", mediaType == "application/xhtml+xml":
+		return kindHTML, mediaType
+	case mediaType == "application/pdf":
+		return kindPDF, mediaType
+	case mediaType == "application/json", strings.HasSuffix(mediaType, "+json"):
+		return kindJSON, mediaType
+	case strings.HasPrefix(mediaType, "image/"):
+		return kindImage, mediaType
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/xml",
+		strings.HasSuffix(mediaType, "+xml"),
+		mediaType == "application/javascript":
+		return kindText, mediaType
+	}
+	return kindUnsupported, mediaType
+}
+
+// renderResponse turns a successful response body into tool result content
+// according to its content type.
+func renderResponse(resp *http.Response, body []byte, opts fetchOptions) (*mcp.CallToolResult, error) {
+	contentType := resp.Header.Get("Content-Type")
+	kind, mediaType := detectContentKind(contentType, body)
+
+	if kind == kindHTML || kind == kindText {
+		decoded, err := decodeBody(body, contentType)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to decode response body: %v", err)), nil
+		}
+		body = decoded
+	}
+
+	switch kind {
+	case kindHTML:
+		return renderHTML(body, opts)
+	case kindPDF:
+		text, err := extractPDFText(body)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to extract text from PDF: %v", err)), nil
+		}
+		return mcp.NewToolResultText(text), nil
+	case kindJSON:
+		text, err := formatJSON(body, opts.JSONPath)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return mcp.NewToolResultText(text), nil
+	case kindText:
+		return mcp.NewToolResultText(string(body)), nil
+	case kindImage:
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.NewImageContent(base64.StdEncoding.EncodeToString(body), mediaType),
+			},
+		}, nil
+	}
+	return mcp.NewToolResultError(fmt.Sprintf("Unsupported content type: %s", mediaType)), nil
+}
+
+// httpErrorResult reports a non-2xx response together with the start of its body
+func httpErrorResult(resp *http.Response, body []byte) *mcp.CallToolResult {
+	snippet := truncateUTF8(body, errorSnippetSize)
+	return mcp.NewToolResultError(fmt.Sprintf("HTTP error: %s\n\n%s", resp.Status, strings.TrimSpace(string(snippet))))
+}
+
+// truncateUTF8 cuts b to at most n bytes, stepping back to the start of a
+// rune so UTF-8 text is not cut in the middle of a character
+func truncateUTF8(b []byte, n int) []byte {
+	if len(b) <= n {
+		return b
+	}
+	cut := n
+	for cut > 0 && !utf8.RuneStart(b[cut]) {
+		cut--
+	}
+	return b[:cut]
+}
+
+func renderHTML(body []byte, opts fetchOptions) (*mcp.CallToolResult, error) {
+	if opts.AsHTML {
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "html",
+					Text: string(body),
+				},
+			},
+		}, nil
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	text := htmlToMarkdown(doc)
+	if opts.Metadata {
+		text = extractMetadata(doc, opts.BaseURL).header() + text
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: text,
+			},
+		},
+	}, nil
+}
+
+// htmlToMarkdown performs a basic HTML to Markdown conversion
+func htmlToMarkdown(doc *goquery.Document) string {
+	var markdown strings.Builder
+	doc.Find("body").Each(func(i int, s *goquery.Selection) {
+		s.Find("h1,h2,h3,h4,h5,h6").Each(func(i int, h *goquery.Selection) {
+			markdown.WriteString("#" + strings.Repeat("#", i))
+			markdown.WriteString(" " + h.Text() + "\n\n")
+		})
+		s.Find("p").Each(func(i int, p *goquery.Selection) {
+			markdown.WriteString(p.Text() + "\n\n")
+		})
+		s.Find("a").Each(func(i int, a *goquery.Selection) {
+			href, exists := a.Attr("href")
+			if exists {
+				markdown.WriteString("[" + a.Text() + "](" + href + ")\n")
+			}
+		})
+	})
+	return markdown.String()
+}
+
+func extractPDFText(body []byte) (string, error) {
+	reader, err := pdf.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return "", err
+	}
+	plain, err := reader.GetPlainText()
+	if err != nil {
+		return "", err
+	}
+	text, err := io.ReadAll(plain)
+	if err != nil {
+		return "", err
+	}
+	return string(text), nil
+}
+
+// formatJSON pretty-prints a JSON document, optionally narrowing it down to the
+// values selected by a JSONPath expression first.
+func formatJSON(body []byte, path string) (string, error) {
+	if path == "" {
+		var out bytes.Buffer
+		if err := json.Indent(&out, body, "", "  "); err != nil {
+			return "", fmt.Errorf("invalid JSON response: %v", err)
+		}
+		return out.String(), nil
+	}
+
+	expr, err := jp.ParseString(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid JSONPath %q: %v", path, err)
+	}
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return "", fmt.Errorf("invalid JSON response: %v", err)
+	}
+	out, err := json.MarshalIndent(expr.Get(data), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
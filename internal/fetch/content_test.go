@@ -0,0 +1,143 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/dyike/MonoMCPHub/internal/fetch/config"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// newTestService returns a fetch service that may reach httptest servers on loopback
+func newTestService(cfg *config.FetchConfig) *FetchService {
+	if cfg == nil {
+		cfg = config.NewFetchConfig()
+	}
+	cfg.AllowedHosts = append(cfg.AllowedHosts, "127.0.0.1")
+	return NewFetchService(context.Background(), cfg)
+}
+
+func newFetchRequest(args map[string]interface{}) mcp.CallToolRequest {
+	var request mcp.CallToolRequest
+	request.Params.Name = "fetch_url"
+	request.Params.Arguments = args
+	return request
+}
+
+func TestDetectContentKind(t *testing.T) {
+	cases := []struct {
+		contentType string
+		body        string
+		want        contentKind
+	}{
+		{"text/html; charset=utf-8", "", kindHTML},
+		{"application/pdf", "", kindPDF},
+		{"application/json", "", kindJSON},
+		{"application/problem+json", "", kindJSON},
+		{"text/plain", "", kindText},
+		{"image/png", "", kindImage},
+		{"", "<!DOCTYPE html><html></html>", kindHTML},
+		{"application/octet-stream", "%PDF-1.4", kindPDF},
+		{"application/zip", "", kindUnsupported},
+	}
+	for _, c := range cases {
+		got, _ := detectContentKind(c.contentType, []byte(c.body))
+		if got != c.want {
+			t.Errorf("detectContentKind(%q) = %v, want %v", c.contentType, got, c.want)
+		}
+	}
+}
+
+func TestFormatJSON(t *testing.T) {
+	body := []byte(`{"items":[{"name":"a"},{"name":"b"}]}`)
+
+	out, err := formatJSON(body, "")
+	if err != nil {
+		t.Fatalf("formatJSON failed: %v", err)
+	}
+	if !strings.Contains(out, "\n  \"items\"") {
+		t.Errorf("expected indented JSON, got %s", out)
+	}
+
+	out, err = formatJSON(body, "$.items[*].name")
+	if err != nil {
+		t.Fatalf("formatJSON with path failed: %v", err)
+	}
+	if !strings.Contains(out, `"a"`) || !strings.Contains(out, `"b"`) || strings.Contains(out, "items") {
+		t.Errorf("unexpected JSONPath result: %s", out)
+	}
+}
+
+func TestFetchURLContentTypes(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.Error(w, "no such page", http.StatusNotFound)
+		case "/text":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("plain body"))
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("\x89PNG\r\n\x1a\n"))
+		}
+	}))
+	defer ts.Close()
+
+	fs := newTestService(nil)
+
+	result, err := fs.handleFetchURL(context.Background(), newFetchRequest(map[string]interface{}{"url": ts.URL + "/missing"}))
+	if err != nil {
+		t.Fatalf("handleFetchURL failed: %v", err)
+	}
+	if !result.IsError {
+		t.Errorf("expected 404 to be reported as an error")
+	}
+	if text, ok := mcp.AsTextContent(result.Content[0]); !ok || !strings.Contains(text.Text, "404") || !strings.Contains(text.Text, "no such page") {
+		t.Errorf("expected status and body snippet, got %v", result.Content[0])
+	}
+
+	result, err = fs.handleFetchURL(context.Background(), newFetchRequest(map[string]interface{}{"url": ts.URL + "/text"}))
+	if err != nil {
+		t.Fatalf("handleFetchURL failed: %v", err)
+	}
+	if text, ok := mcp.AsTextContent(result.Content[0]); !ok || text.Text != "plain body" {
+		t.Errorf("expected plain text passthrough, got %v", result.Content[0])
+	}
+
+	result, err = fs.handleFetchURL(context.Background(), newFetchRequest(map[string]interface{}{"url": ts.URL + "/image"}))
+	if err != nil {
+		t.Fatalf("handleFetchURL failed: %v", err)
+	}
+	if image, ok := mcp.AsImageContent(result.Content[0]); !ok || image.MIMEType != "image/png" {
+		t.Errorf("expected image content, got %v", result.Content[0])
+	}
+}
+
+func TestIsNearlyEmpty(t *testing.T) {
+	shell := []byte(`<html><body><div id="root"></div><script>` + strings.Repeat("var a = 1;", 100) + `</script></body></html>`)
+	if !isNearlyEmpty(shell) {
+		t.Errorf("expected JavaScript shell to be nearly empty")
+	}
+	page := []byte(`<html><body><p>` + strings.Repeat("Some real content. ", 20) + `</p></body></html>`)
+	if isNearlyEmpty(page) {
+		t.Errorf("expected page with text not to be nearly empty")
+	}
+}
+
+func TestTruncateUTF8(t *testing.T) {
+	s := []byte("héllo wörld")
+	for n := 0; n <= len(s)+1; n++ {
+		got := truncateUTF8(s, n)
+		if len(got) > n || !utf8.Valid(got) || !bytes.HasPrefix(s, got) {
+			t.Errorf("truncateUTF8(%q, %d) = %q", s, n, got)
+		}
+	}
+	if got := truncateUTF8(s, 2); string(got) != "h" {
+		t.Errorf("expected the cut to step back before é, got %q", got)
+	}
+}
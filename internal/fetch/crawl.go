@@ -0,0 +1,448 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/xmlquery"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	crawlOutputCombined = "combined"
+	crawlOutputPages    = "pages"
+
+	// maxCrawlPages and maxCrawlConcurrency bound what a single crawl may ask for
+	maxCrawlPages       = 500
+	maxCrawlConcurrency = 16
+
+	// maxSitemaps and maxSitemapDepth bound how much of a sitemap index is read
+	maxSitemaps     = 50
+	maxSitemapDepth = 3
+)
+
+// CrawlPage is the result of crawling a single page
+type CrawlPage struct {
+	URL      string `json:"url"`
+	Title    string `json:"title,omitempty"`
+	Depth    int    `json:"depth"`
+	Markdown string `json:"markdown,omitempty"`
+	Error    string `json:"error,omitempty"`
+
+	links []string
+}
+
+// crawlOptions controls which links a crawl follows
+type crawlOptions struct {
+	MaxDepth    int
+	MaxPages    int
+	Concurrency int
+	Scope       *url.URL
+	Include     []*regexp.Regexp
+	Exclude     []*regexp.Regexp
+}
+
+func newCrawlTool() mcp.Tool {
+	return mcp.NewTool("fetch_crawl",
+		mcp.WithDescription("Crawl a site breadth-first from a URL or its sitemap and return the pages as Markdown"),
+		mcp.WithString("url",
+			mcp.Required(),
+			mcp.Description("The URL to start crawling from"),
+		),
+		mcp.WithNumber("max_depth",
+			mcp.Description("Maximum link depth to follow from the start URL"),
+			mcp.DefaultNumber(2),
+		),
+		mcp.WithNumber("max_pages",
+			mcp.Description(fmt.Sprintf("Maximum number of pages to fetch, at most %d", maxCrawlPages)),
+			mcp.DefaultNumber(20),
+		),
+		mcp.WithNumber("concurrency",
+			mcp.Description(fmt.Sprintf("Number of pages fetched in parallel, at most %d", maxCrawlConcurrency)),
+			mcp.DefaultNumber(4),
+		),
+		mcp.WithString("scope",
+			mcp.Description("URL prefix the crawl must stay within, defaults to the directory of the start URL"),
+		),
+		mcp.WithArray("include",
+			mcp.Description("Regular expressions; when set, only URLs matching one of them are fetched"),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+		),
+		mcp.WithArray("exclude",
+			mcp.Description("Regular expressions for URLs that are never fetched"),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+		),
+		mcp.WithBoolean("use_sitemap",
+			mcp.Description("Seed the crawl from the site's sitemap.xml, or from the start URL if it is a sitemap itself"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithString("output",
+			mcp.Description("Return one combined Markdown document or a JSON list of per-page results"),
+			mcp.DefaultString(crawlOutputCombined),
+			mcp.Enum(crawlOutputCombined, crawlOutputPages),
+		),
+	)
+}
+
+func (fs *FetchService) handleCrawl(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+
+	rawURL, ok := args["url"].(string)
+	if !ok || rawURL == "" {
+		return mcp.NewToolResultError("url must be a string"), nil
+	}
+	start, err := url.Parse(rawURL)
+	if err != nil || start.Host == "" {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid url: %s", rawURL)), nil
+	}
+
+	opts := crawlOptions{
+		MaxDepth:    intArg(args, "max_depth", 2),
+		MaxPages:    intArg(args, "max_pages", 20),
+		Concurrency: intArg(args, "concurrency", 4),
+	}
+	if opts.MaxPages < 1 {
+		return mcp.NewToolResultError("max_pages must be at least 1"), nil
+	}
+	if opts.Concurrency < 1 {
+		return mcp.NewToolResultError("concurrency must be at least 1"), nil
+	}
+	opts.MaxPages = min(opts.MaxPages, maxCrawlPages)
+	opts.Concurrency = min(opts.Concurrency, maxCrawlConcurrency)
+
+	scope := defaultScope(start)
+	if s, ok := args["scope"].(string); ok && s != "" {
+		scope = s
+	}
+	if opts.Scope, err = url.Parse(scope); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid scope: %v", err)), nil
+	}
+	if opts.Include, err = regexpsArg(args, "include"); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if opts.Exclude, err = regexpsArg(args, "exclude"); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	seeds := []string{start.String()}
+	if useSitemap, _ := args["use_sitemap"].(bool); useSitemap {
+		seeds, err = fs.sitemapURLs(ctx, start, opts)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to read sitemap: %v", err)), nil
+		}
+	}
+
+	pages := fs.crawl(ctx, seeds, opts, func(done int) {
+		sendProgress(ctx, request, float64(done), float64(opts.MaxPages))
+	})
+
+	if output, _ := args["output"].(string); output == crawlOutputPages {
+		payload, err := json.MarshalIndent(pages, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return mcp.NewToolResultText(string(payload)), nil
+	}
+
+	var combined strings.Builder
+	for i, p := range pages {
+		if i > 0 {
+			combined.WriteString("\n---\n\n")
+		}
+		title := p.Title
+		if title == "" {
+			title = p.URL
+		}
+		combined.WriteString("# " + title + "\n\nSource: " + p.URL + "\n\n")
+		if p.Error != "" {
+			combined.WriteString("Error: " + p.Error + "\n")
+		} else {
+			combined.WriteString(p.Markdown)
+		}
+	}
+	return mcp.NewToolResultText(combined.String()), nil
+}
+
+// crawl fetches pages breadth-first, one depth level at a time, calling
+// progress after every page that completes.
+func (fs *FetchService) crawl(ctx context.Context, seeds []string, opts crawlOptions, progress func(done int)) []*CrawlPage {
+	visited := make(map[string]bool)
+	var pages []*CrawlPage
+
+	level := make([]string, 0, len(seeds))
+	for _, seed := range seeds {
+		if u := normalizeURL(seed); u != "" && !visited[u] && opts.allowed(u) {
+			visited[u] = true
+			level = append(level, u)
+		}
+	}
+
+	done := 0
+	for depth := 0; len(level) > 0 && ctx.Err() == nil; depth++ {
+		if remaining := opts.MaxPages - len(pages); len(level) > remaining {
+			level = level[:remaining]
+		}
+
+		results := make([]*CrawlPage, len(level))
+		sem := make(chan struct{}, opts.Concurrency)
+		var mu sync.Mutex
+		var wg sync.WaitGroup
+		for i, u := range level {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer wg.Done()
+				defer func() { <-sem }()
+				results[i] = fs.crawlPage(ctx, u, depth)
+
+				mu.Lock()
+				defer mu.Unlock()
+				done++
+				progress(done)
+			}()
+		}
+		wg.Wait()
+		pages = append(pages, results...)
+
+		if len(pages) >= opts.MaxPages || depth >= opts.MaxDepth {
+			break
+		}
+		var next []string
+		for _, p := range results {
+			for _, link := range p.links {
+				if !visited[link] && opts.allowed(link) {
+					visited[link] = true
+					next = append(next, link)
+				}
+			}
+		}
+		level = next
+	}
+	return pages
+}
+
+func (fs *FetchService) crawlPage(ctx context.Context, pageURL string, depth int) *CrawlPage {
+	page := &CrawlPage{URL: pageURL, Depth: depth}
+
+	resp, body, err := fs.get(ctx, pageURL)
+	if err != nil {
+		page.Error = err.Error()
+		return page
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		page.Error = resp.Status
+		return page
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	kind, mediaType := detectContentKind(contentType, body)
+	if kind != kindHTML && kind != kindText {
+		page.Error = fmt.Sprintf("skipped content type %s", mediaType)
+		return page
+	}
+	body, err = decodeBody(body, contentType)
+	if err != nil {
+		page.Error = err.Error()
+		return page
+	}
+	if kind == kindText {
+		page.Markdown = string(body)
+		return page
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		page.Error = err.Error()
+		return page
+	}
+	page.Title = strings.TrimSpace(doc.Find("title").First().Text())
+	page.Markdown = htmlToMarkdown(doc)
+
+	base := resp.Request.URL
+	doc.Find("a[href]").Each(func(i int, a *goquery.Selection) {
+		href, _ := a.Attr("href")
+		ref, err := base.Parse(href)
+		if err != nil {
+			return
+		}
+		if link := normalizeURL(ref.String()); link != "" {
+			page.links = append(page.links, link)
+		}
+	})
+	return page
+}
+
+// sitemapURLs returns up to opts.MaxPages page URLs the crawl may visit from
+// the site's sitemap, following sitemap indexes at most maxSitemapDepth deep
+// and reading at most maxSitemaps sitemaps.
+func (fs *FetchService) sitemapURLs(ctx context.Context, start *url.URL, opts crawlOptions) ([]string, error) {
+	sitemap := start.String()
+	if !strings.HasSuffix(start.Path, ".xml") {
+		sitemap = start.ResolveReference(&url.URL{Path: "/sitemap.xml"}).String()
+	}
+
+	var locs []string
+	seen := map[string]bool{sitemap: true}
+	found := make(map[string]bool)
+	level := []string{sitemap}
+	read := 0
+	for depth := 0; depth < maxSitemapDepth && len(level) > 0; depth++ {
+		var next []string
+		for _, sm := range level {
+			if read == maxSitemaps || len(locs) >= opts.MaxPages {
+				return locs, nil
+			}
+			read++
+			pages, nested, err := fs.readSitemap(ctx, sm)
+			if err != nil {
+				// Only the sitemap asked for has to be there.
+				if sm == sitemap {
+					return nil, err
+				}
+				continue
+			}
+			for _, p := range pages {
+				if u := normalizeURL(p); u != "" && !found[u] && opts.allowed(u) {
+					found[u] = true
+					locs = append(locs, u)
+					if len(locs) == opts.MaxPages {
+						return locs, nil
+					}
+				}
+			}
+			for _, n := range nested {
+				if !seen[n] {
+					seen[n] = true
+					next = append(next, n)
+				}
+			}
+		}
+		level = next
+	}
+	return locs, nil
+}
+
+func (fs *FetchService) readSitemap(ctx context.Context, sitemap string) ([]string, []string, error) {
+	resp, body, err := fs.get(ctx, sitemap)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, nil, fmt.Errorf("%s: %s", sitemap, resp.Status)
+	}
+
+	doc, err := xmlquery.Parse(bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	var locs, nested []string
+	for _, n := range xmlquery.Find(doc, "//url/loc") {
+		locs = append(locs, strings.TrimSpace(n.InnerText()))
+	}
+	for _, n := range xmlquery.Find(doc, "//sitemap/loc") {
+		nested = append(nested, strings.TrimSpace(n.InnerText()))
+	}
+	return locs, nested, nil
+}
+
+func (o crawlOptions) allowed(u string) bool {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return false
+	}
+	if !strings.EqualFold(parsed.Host, o.Scope.Host) || !strings.HasPrefix(parsed.Path, o.Scope.Path) {
+		return false
+	}
+	for _, re := range o.Exclude {
+		if re.MatchString(u) {
+			return false
+		}
+	}
+	if len(o.Include) == 0 {
+		return true
+	}
+	for _, re := range o.Include {
+		if re.MatchString(u) {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultScope keeps a crawl inside the directory of its start URL
+func defaultScope(start *url.URL) string {
+	scope := *start
+	scope.RawQuery = ""
+	scope.Fragment = ""
+	if scope.Path == "" {
+		scope.Path = "/"
+	}
+	if !strings.HasSuffix(scope.Path, "/") {
+		scope.Path = path.Dir(scope.Path)
+		if !strings.HasSuffix(scope.Path, "/") {
+			scope.Path += "/"
+		}
+	}
+	return scope.String()
+}
+
+// normalizeURL drops fragments so the same page is only visited once, and
+// rejects anything that is not http(s).
+func normalizeURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	u.Fragment = ""
+	return u.String()
+}
+
+// sendProgress notifies the client about tool progress when it asked for it
+func sendProgress(ctx context.Context, request mcp.CallToolRequest, progress, total float64) {
+	if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return
+	}
+	srv := server.ServerFromContext(ctx)
+	if srv == nil {
+		return
+	}
+	_ = srv.SendNotificationToClient(ctx, "notifications/progress", map[string]interface{}{
+		"progressToken": request.Params.Meta.ProgressToken,
+		"progress":      progress,
+		"total":         total,
+	})
+}
+
+func intArg(args map[string]interface{}, name string, def int) int {
+	if v, ok := args[name].(float64); ok {
+		return int(v)
+	}
+	return def
+}
+
+func regexpsArg(args map[string]interface{}, name string) ([]*regexp.Regexp, error) {
+	values, _ := args[name].([]interface{})
+	res := make([]*regexp.Regexp, 0, len(values))
+	for _, v := range values {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s must be a list of strings", name)
+		}
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s pattern %q: %v", name, s, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
@@ -0,0 +1,132 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCrawl(t *testing.T) {
+	links := map[string]string{
+		"/docs/":        `<a href="/docs/a">a</a> <a href="b#top">b</a> <a href="/blog/">blog</a>`,
+		"/docs/a":       `<a href="/docs/">home</a> <a href="/docs/private">private</a>`,
+		"/docs/b":       `<a href="/docs/c">c</a>`,
+		"/docs/c":       ``,
+		"/docs/private": ``,
+		"/blog/":        ``,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := links[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>%s</title></head><body>%s</body></html>", r.URL.Path, body)
+	}))
+	defer ts.Close()
+
+	fs := newTestService(nil)
+	scope, _ := url.Parse(ts.URL + "/docs/")
+	opts := crawlOptions{
+		MaxDepth:    1,
+		MaxPages:    10,
+		Concurrency: 2,
+		Scope:       scope,
+		Exclude:     []*regexp.Regexp{regexp.MustCompile(`/private$`)},
+	}
+
+	progress := 0
+	pages := fs.crawl(context.Background(), []string{ts.URL + "/docs/"}, opts, func(done int) {
+		progress = done
+	})
+
+	want := []string{"/docs/", "/docs/a", "/docs/b"}
+	if len(pages) != len(want) {
+		t.Fatalf("expected %d pages, got %d: %v", len(want), len(pages), pages)
+	}
+	for i, p := range pages {
+		if p.URL != ts.URL+want[i] {
+			t.Errorf("page %d: expected %s, got %s", i, ts.URL+want[i], p.URL)
+		}
+		if p.Title != want[i] {
+			t.Errorf("page %d: expected title %s, got %s", i, want[i], p.Title)
+		}
+	}
+	if progress != len(want) {
+		t.Errorf("expected %d progress updates, got %d", len(want), progress)
+	}
+}
+
+func TestDefaultScope(t *testing.T) {
+	cases := map[string]string{
+		"https://example.com/docs/guide/intro.html?x=1": "https://example.com/docs/guide/",
+		"https://example.com/docs/":                     "https://example.com/docs/",
+		"https://example.com":                           "https://example.com/",
+	}
+	for in, want := range cases {
+		u, _ := url.Parse(in)
+		if got := defaultScope(u); got != want {
+			t.Errorf("defaultScope(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestCrawlRejectsBadLimits(t *testing.T) {
+	fs := newTestService(nil)
+	for _, args := range []map[string]interface{}{
+		{"url": "https://example.com/", "max_pages": float64(-1)},
+		{"url": "https://example.com/", "max_pages": float64(0)},
+		{"url": "https://example.com/", "concurrency": float64(-3)},
+	} {
+		res, err := fs.handleCrawl(context.Background(), newFetchRequest(args))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.IsError {
+			t.Errorf("expected %v to be rejected", args)
+		}
+	}
+}
+
+func TestSitemapURLs(t *testing.T) {
+	var read atomic.Int32
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		read.Add(1)
+		w.Header().Set("Content-Type", "application/xml")
+		// Every sitemap is an index of two more, each listing two pages.
+		n := r.URL.Query().Get("n")
+		fmt.Fprintf(w, `<sitemapindex><sitemap><loc>%[1]s/sitemap.xml?n=%[2]sa</loc></sitemap><sitemap><loc>%[1]s/sitemap.xml?n=%[2]sb</loc></sitemap></sitemapindex>
+<urlset><url><loc>%[1]s/%[2]s/1</loc></url><url><loc>%[1]s/%[2]s/2</loc></url><url><loc>https://elsewhere.test/</loc></url></urlset>`, ts.URL, n)
+	}))
+	defer ts.Close()
+
+	fs := newTestService(nil)
+	start, _ := url.Parse(ts.URL + "/")
+	opts := crawlOptions{MaxPages: 5, Scope: start}
+	urls, err := fs.sitemapURLs(context.Background(), start, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Out of scope pages don't count, reading stops once there are enough.
+	if len(urls) != 5 || read.Load() != 3 {
+		t.Errorf("expected 5 URLs from 3 sitemaps, got %v from %d", urls, read.Load())
+	}
+
+	read.Store(0)
+	opts.MaxPages = maxCrawlPages
+	urls, err = fs.sitemapURLs(context.Background(), start, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1 + 2 + 4 sitemaps in maxSitemapDepth levels
+	if read.Load() != 7 || len(urls) != 14 {
+		t.Errorf("expected 14 URLs from 7 sitemaps, got %d from %d", len(urls), read.Load())
+	}
+}
@@ -0,0 +1,379 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/xmlquery"
+	"github.com/mark3labs/mcp-go/mcp"
+	nethtml "golang.org/x/net/html"
+)
+
+const (
+	attrText      = "text"
+	attrHTML      = "html"
+	attrOuterHTML = "outer_html"
+)
+
+// extractField describes how to pull one value out of a node. Exactly one of
+// Selector (CSS) and XPath is set; a field with Fields is a repeat group that
+// yields one nested record per match.
+type extractField struct {
+	Selector string                   `json:"selector"`
+	XPath    string                   `json:"xpath"`
+	Attr     string                   `json:"attr"`
+	List     bool                     `json:"list"`
+	Fields   map[string]*extractField `json:"fields"`
+}
+
+// UnmarshalJSON accepts either a full field object or a bare selector string,
+// which is treated as XPath when it starts like a path and as CSS otherwise.
+func (f *extractField) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		if isXPath(s) {
+			f.XPath = s
+		} else {
+			f.Selector = s
+		}
+		return nil
+	}
+	type plain extractField
+	return json.Unmarshal(data, (*plain)(f))
+}
+
+func (f *extractField) validate(name string) error {
+	if f.Selector != "" && f.XPath != "" {
+		return fmt.Errorf("field %q has both selector and xpath", name)
+	}
+	// Only the schema root may omit a selector, it then covers the whole page.
+	if name != "" && f.Selector == "" && f.XPath == "" {
+		return fmt.Errorf("field %q needs a selector or xpath", name)
+	}
+	for child, cf := range f.Fields {
+		if cf == nil {
+			return fmt.Errorf("field %q must be an object", child)
+		}
+		if err := cf.validate(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isXPath(s string) bool {
+	return strings.HasPrefix(s, "/") || strings.HasPrefix(s, "./") || strings.HasPrefix(s, "(")
+}
+
+// extractNode is an element in either the goquery or the xmlquery tree, so
+// CSS and XPath fields can be mixed freely in one schema.
+type extractNode interface {
+	css(selector string) []extractNode
+	xpath(expr string) ([]extractNode, error)
+	value(attr string) (string, bool)
+}
+
+type cssNode struct {
+	sel  *goquery.Selection
+	tree *xmlquery.Node
+}
+
+func (n *cssNode) css(selector string) []extractNode {
+	var nodes []extractNode
+	n.sel.Find(selector).Each(func(i int, s *goquery.Selection) {
+		nodes = append(nodes, &cssNode{sel: s})
+	})
+	return nodes
+}
+
+func (n *cssNode) xpath(expr string) ([]extractNode, error) {
+	if n.tree == nil {
+		tree, err := xpathTree(n.sel)
+		if err != nil {
+			return nil, err
+		}
+		n.tree = tree
+	}
+	return xpathNode{n.tree}.xpath(expr)
+}
+
+func (n *cssNode) value(attr string) (string, bool) {
+	switch attr {
+	case "", attrText:
+		return strings.TrimSpace(n.sel.Text()), true
+	case attrHTML:
+		html, err := n.sel.Html()
+		return html, err == nil
+	case attrOuterHTML:
+		html, err := goquery.OuterHtml(n.sel)
+		return html, err == nil
+	}
+	return n.sel.Attr(attr)
+}
+
+type xpathNode struct {
+	node *xmlquery.Node
+}
+
+func (n xpathNode) css(selector string) []extractNode {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(n.node.OutputXML(true)))
+	if err != nil {
+		return nil
+	}
+	root := &cssNode{sel: doc.Selection}
+	return root.css(selector)
+}
+
+func (n xpathNode) xpath(expr string) ([]extractNode, error) {
+	found, err := xmlquery.QueryAll(n.node, expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid xpath %q: %v", expr, err)
+	}
+	nodes := make([]extractNode, 0, len(found))
+	for _, f := range found {
+		nodes = append(nodes, xpathNode{f})
+	}
+	return nodes, nil
+}
+
+func (n xpathNode) value(attr string) (string, bool) {
+	switch attr {
+	case "", attrText:
+		return strings.TrimSpace(n.node.InnerText()), true
+	case attrHTML:
+		return n.node.OutputXML(false), true
+	case attrOuterHTML:
+		return n.node.OutputXML(true), true
+	}
+	for _, a := range n.node.Attr {
+		if a.Name.Local == attr {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// xpathTree converts a goquery selection into an xmlquery tree. goquery's
+// serialization is well-formed apart from script and style bodies, which are
+// dropped. For an element the element itself is returned, so relative
+// expressions evaluate against it like they do for CSS.
+func xpathTree(sel *goquery.Selection) (*xmlquery.Node, error) {
+	clean := sel.Clone()
+	clean.Find("script,style").Remove()
+	html, err := goquery.OuterHtml(clean)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := xmlquery.ParseWithOptions(strings.NewReader(html), xmlquery.ParserOptions{
+		Decoder: &xmlquery.DecoderOptions{
+			Strict:    false,
+			AutoClose: xml.HTMLAutoClose,
+			Entity:    xml.HTMLEntity,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if sel.Length() > 0 && sel.Get(0).Type == nethtml.DocumentNode {
+		return doc, nil
+	}
+	for c := doc.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == xmlquery.ElementNode {
+			return c, nil
+		}
+	}
+	return doc, nil
+}
+
+// extractor applies a schema to a document
+type extractor struct {
+	base *url.URL
+}
+
+func (e *extractor) find(n extractNode, f *extractField) ([]extractNode, error) {
+	if f.XPath != "" {
+		return n.xpath(f.XPath)
+	}
+	return n.css(f.Selector), nil
+}
+
+func (e *extractor) records(n extractNode, fields map[string]*extractField) (map[string]interface{}, error) {
+	record := make(map[string]interface{}, len(fields))
+	for name, f := range fields {
+		v, err := e.field(n, f)
+		if err != nil {
+			return nil, err
+		}
+		record[name] = v
+	}
+	return record, nil
+}
+
+func (e *extractor) field(n extractNode, f *extractField) (interface{}, error) {
+	matches, err := e.find(n, f)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(f.Fields) > 0 {
+		group := make([]map[string]interface{}, 0, len(matches))
+		for _, m := range matches {
+			record, err := e.records(m, f.Fields)
+			if err != nil {
+				return nil, err
+			}
+			group = append(group, record)
+		}
+		return group, nil
+	}
+
+	if f.List {
+		values := make([]string, 0, len(matches))
+		for _, m := range matches {
+			if v, ok := m.value(f.Attr); ok {
+				values = append(values, e.resolve(f.Attr, v))
+			}
+		}
+		return values, nil
+	}
+
+	if len(matches) == 0 {
+		return nil, nil
+	}
+	v, ok := matches[0].value(f.Attr)
+	if !ok {
+		return nil, nil
+	}
+	return e.resolve(f.Attr, v), nil
+}
+
+// resolve turns relative link attributes into absolute URLs
+func (e *extractor) resolve(attr, v string) string {
+	if e.base == nil || (attr != "href" && attr != "src") {
+		return v
+	}
+	ref, err := e.base.Parse(v)
+	if err != nil {
+		return v
+	}
+	return ref.String()
+}
+
+// extract applies schema to an HTML document and returns one record per match
+// of the schema's top-level selector, or a single record when it has none.
+func extract(html []byte, base *url.URL, schema *extractField) ([]map[string]interface{}, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
+	if err != nil {
+		return nil, err
+	}
+	e := &extractor{base: base}
+
+	var root extractNode = &cssNode{sel: doc.Selection}
+	roots := []extractNode{root}
+	if schema.Selector != "" || schema.XPath != "" {
+		if roots, err = e.find(root, schema); err != nil {
+			return nil, err
+		}
+	}
+
+	records := make([]map[string]interface{}, 0, len(roots))
+	for _, r := range roots {
+		record, err := e.records(r, schema.Fields)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func newExtractTool() mcp.Tool {
+	return mcp.NewTool("fetch_extract",
+		mcp.WithDescription(`Extract structured JSON records from a web page with CSS selectors or XPath. `+
+			`The schema has an optional top-level "selector" or "xpath" whose matches each become a record, and "fields" mapping names to a selector string `+
+			`or to an object with "selector" or "xpath", "attr" (text, html, outer_html or an attribute name), "list" to collect all matches, `+
+			`and nested "fields" for repeat groups. Example: {"selector": ".product", "fields": {"name": "h2", "url": {"selector": "a", "attr": "href"}}}`),
+		mcp.WithString("url",
+			mcp.Description("The URL of the page to extract from"),
+		),
+		mcp.WithString("html",
+			mcp.Description("Raw HTML to extract from instead of fetching a URL"),
+		),
+		mcp.WithObject("schema",
+			mcp.Required(),
+			mcp.Description("The extraction schema"),
+		),
+	)
+}
+
+func (fs *FetchService) handleExtract(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+
+	rawSchema, ok := args["schema"].(map[string]interface{})
+	if !ok {
+		return mcp.NewToolResultError("schema must be an object"), nil
+	}
+	data, err := json.Marshal(rawSchema)
+	if err != nil {
+		return nil, err
+	}
+	var schema extractField
+	if err := json.Unmarshal(data, &schema); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid schema: %v", err)), nil
+	}
+	if len(schema.Fields) == 0 {
+		return mcp.NewToolResultError("Invalid schema: fields must not be empty"), nil
+	}
+	if err := schema.validate(""); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid schema: %v", err)), nil
+	}
+
+	html, base, err := fs.extractSource(ctx, args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	records, err := extract(html, base, &schema)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to extract: %v", err)), nil
+	}
+	payload, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(string(payload)), nil
+}
+
+// extractSource returns the HTML to extract from, either given inline or
+// fetched from the url argument, together with the URL to resolve links against.
+func (fs *FetchService) extractSource(ctx context.Context, args map[string]interface{}) ([]byte, *url.URL, error) {
+	rawURL, _ := args["url"].(string)
+	if html, ok := args["html"].(string); ok && html != "" {
+		base, _ := url.Parse(rawURL)
+		return []byte(html), base, nil
+	}
+	if rawURL == "" {
+		return nil, nil, errors.New("either url or html is required")
+	}
+
+	resp, body, err := fs.get(ctx, rawURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, nil, fmt.Errorf("HTTP error: %s", resp.Status)
+	}
+	body, err = decodeBody(body, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, resp.Request.URL, nil
+}
@@ -0,0 +1,101 @@
+package fetch
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+const productsHTML = `<html><head><script>if (a < b) {}</script></head><body>
+<div class="product" data-sku="A1">
+  <h2>Widget</h2><span class="price">9.99</span><a href="/p/a1">details</a>
+  <ul><li class="tag">new</li><li class="tag">sale</li></ul>
+  <div class="review"><b>ann</b><i>5</i></div>
+  <div class="review"><b>bob</b><i>3</i></div>
+</div>
+<div class="product" data-sku="B2">
+  <h2>Gadget&nbsp;Pro</h2><span class="price">19.50</span><a href="p/b2">details</a>
+</div>
+</body></html>`
+
+func TestExtract(t *testing.T) {
+	schemaJSON := `{
+		"selector": ".product",
+		"fields": {
+			"name": "h2",
+			"price": "./span[@class='price']",
+			"url": {"selector": "a", "attr": "href"},
+			"tags": {"selector": ".tag", "list": true},
+			"reviews": {"selector": ".review", "fields": {"author": "b", "stars": {"xpath": "./i"}}}
+		}
+	}`
+	var schema extractField
+	if err := json.Unmarshal([]byte(schemaJSON), &schema); err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+	if err := schema.validate(""); err != nil {
+		t.Fatalf("schema is invalid: %v", err)
+	}
+
+	base, _ := url.Parse("https://shop.example.com/list/")
+	records, err := extract([]byte(productsHTML), base, &schema)
+	if err != nil {
+		t.Fatalf("extract failed: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	first := records[0]
+	if first["name"] != "Widget" || first["price"] != "9.99" || first["url"] != "https://shop.example.com/p/a1" {
+		t.Errorf("unexpected first record: %v", first)
+	}
+	if !reflect.DeepEqual(first["tags"], []string{"new", "sale"}) {
+		t.Errorf("unexpected tags: %v", first["tags"])
+	}
+	reviews, ok := first["reviews"].([]map[string]interface{})
+	if !ok || len(reviews) != 2 || reviews[1]["author"] != "bob" || reviews[1]["stars"] != "3" {
+		t.Errorf("unexpected reviews: %v", first["reviews"])
+	}
+
+	second := records[1]
+	if second["name"] != "Gadget\u00a0Pro" || second["url"] != "https://shop.example.com/list/p/b2" {
+		t.Errorf("unexpected second record: %v", second)
+	}
+	if !reflect.DeepEqual(second["tags"], []string{}) {
+		t.Errorf("expected empty tag list, got %v", second["tags"])
+	}
+}
+
+func TestExtractXPathRoot(t *testing.T) {
+	schema := extractField{
+		XPath: "//div[@class='product']",
+		Fields: map[string]*extractField{
+			"sku":  {XPath: ".", Attr: "data-sku"},
+			"name": {Selector: "h2"},
+		},
+	}
+	records, err := extract([]byte(productsHTML), nil, &schema)
+	if err != nil {
+		t.Fatalf("extract failed: %v", err)
+	}
+	if len(records) != 2 || records[0]["sku"] != "A1" || records[1]["sku"] != "B2" || records[0]["name"] != "Widget" {
+		t.Errorf("unexpected records: %v", records)
+	}
+}
+
+func TestExtractSchemaValidation(t *testing.T) {
+	schema := extractField{Fields: map[string]*extractField{"bad": {}}}
+	if err := schema.validate(""); err == nil {
+		t.Errorf("expected field without selector to be rejected")
+	}
+
+	var nullField extractField
+	if err := json.Unmarshal([]byte(`{"fields": {"a": null}}`), &nullField); err != nil {
+		t.Fatal(err)
+	}
+	if err := nullField.validate(""); err == nil {
+		t.Errorf("expected null field to be rejected")
+	}
+}
@@ -0,0 +1,589 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mark3labs/mcp-go/mcp"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	feedStateFile = "feeds.json"
+
+	// maxSeenEntries bounds how many entry IDs are remembered per feed
+	maxSeenEntries = 500
+	// maxFeedBodies and maxFeedBodySize bound the feed documents kept in
+	// memory for 304 responses, the least recently fetched are dropped first
+	maxFeedBodies   = 32
+	maxFeedBodySize = 1 << 20
+)
+
+var dateLayouts = []string{
+	time.RFC3339,
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC822Z,
+	time.RFC822,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// Feed is a normalized RSS, Atom or JSON Feed document
+type Feed struct {
+	Title   string       `json:"title"`
+	Link    string       `json:"link,omitempty"`
+	Entries []*FeedEntry `json:"entries"`
+}
+
+// FeedEntry is a normalized feed item
+type FeedEntry struct {
+	ID        string     `json:"id"`
+	Title     string     `json:"title"`
+	Link      string     `json:"link,omitempty"`
+	Author    string     `json:"author,omitempty"`
+	Published *time.Time `json:"published,omitempty"`
+	Summary   string     `json:"summary,omitempty"`
+	Content   string     `json:"content,omitempty"`
+}
+
+// feedState is what is remembered about a feed between calls
+type feedState struct {
+	ETag         string   `json:"etag,omitempty"`
+	LastModified string   `json:"last_modified,omitempty"`
+	Seen         []string `json:"seen,omitempty"`
+
+	// body is the last fetched document, kept in memory to answer 304 responses
+	body        []byte
+	contentType string
+}
+
+// feedReader keeps conditional GET validators and seen entries per feed URL
+type feedReader struct {
+	mu     sync.Mutex
+	store  *store
+	states map[string]*feedState
+	// bodies lists the feeds with a body in memory, oldest first
+	bodies []string
+}
+
+func newFeedReader(s *store) *feedReader {
+	fr := &feedReader{
+		store:  s,
+		states: make(map[string]*feedState),
+	}
+	_ = s.load(feedStateFile, &fr.states)
+	return fr
+}
+
+// state returns a copy of what is known about a feed
+func (fr *feedReader) state(feedURL string) feedState {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	st, ok := fr.states[feedURL]
+	if !ok {
+		return feedState{}
+	}
+	cp := *st
+	cp.Seen = slices.Clone(st.Seen)
+	return cp
+}
+
+// update changes the state of a feed and saves all states, both under the
+// lock so a concurrent call never sees or saves a half updated state
+func (fr *feedReader) update(feedURL string, fn func(st *feedState)) error {
+	fr.mu.Lock()
+	defer fr.mu.Unlock()
+	st, ok := fr.states[feedURL]
+	if !ok {
+		st = &feedState{}
+		fr.states[feedURL] = st
+	}
+	fn(st)
+
+	fr.bodies = slices.DeleteFunc(fr.bodies, func(u string) bool { return u == feedURL })
+	if st.body != nil {
+		fr.bodies = append(fr.bodies, feedURL)
+	}
+	for len(fr.bodies) > maxFeedBodies {
+		if old, ok := fr.states[fr.bodies[0]]; ok {
+			old.body, old.contentType = nil, ""
+		}
+		fr.bodies = fr.bodies[1:]
+	}
+	return fr.store.save(feedStateFile, fr.states)
+}
+
+func newFeedTool() mcp.Tool {
+	return mcp.NewTool("fetch_feed",
+		mcp.WithDescription("Read an RSS 2.0, Atom or JSON Feed and return normalized entries with content as Markdown"),
+		mcp.WithString("url",
+			mcp.Required(),
+			mcp.Description("The URL of the feed"),
+		),
+		mcp.WithString("since",
+			mcp.Description("Only return entries published after this date (RFC 3339 or YYYY-MM-DD)"),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of entries to return"),
+			mcp.DefaultNumber(20),
+		),
+		mcp.WithBoolean("only_new",
+			mcp.Description("Only return entries not returned by earlier only_new calls for this feed"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithBoolean("include_content",
+			mcp.Description("Include the full entry content as Markdown"),
+			mcp.DefaultBool(true),
+		),
+	)
+}
+
+func (fs *FetchService) handleFeed(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+
+	feedURL, ok := args["url"].(string)
+	if !ok || feedURL == "" {
+		return mcp.NewToolResultError("url must be a string"), nil
+	}
+	var since time.Time
+	if s, ok := args["since"].(string); ok && s != "" {
+		t, ok := parseDate(s)
+		if !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid since date: %s", s)), nil
+		}
+		since = t
+	}
+	limit := intArg(args, "limit", 20)
+	onlyNew, _ := args["only_new"].(bool)
+	includeContent, ok := args["include_content"].(bool)
+	if !ok {
+		includeContent = true
+	}
+
+	st := fs.feeds.state(feedURL)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	req.Header.Set("Accept", "application/feed+json, application/atom+xml, application/rss+xml, application/xml;q=0.9, */*;q=0.8")
+	// A 304 can only be answered from the cached body. "Nothing new" is no
+	// answer, entries cut by limit or since may not have been returned yet.
+	if st.body != nil {
+		if st.ETag != "" {
+			req.Header.Set("If-None-Match", st.ETag)
+		}
+		if st.LastModified != "" {
+			req.Header.Set("If-Modified-Since", st.LastModified)
+		}
+	}
+
+	resp, body, err := fs.do(req)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to fetch feed: %v", err)), nil
+	}
+	contentType := resp.Header.Get("Content-Type")
+	modified := true
+	switch {
+	case resp.StatusCode == http.StatusNotModified:
+		if st.body == nil {
+			return mcp.NewToolResultText("Feed not modified since the last check, no new entries"), nil
+		}
+		body, contentType = st.body, st.contentType
+		modified = false
+	case resp.StatusCode >= http.StatusBadRequest:
+		return httpErrorResult(resp, body), nil
+	}
+
+	feed, err := parseFeed(body, contentType)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse feed: %v", err)), nil
+	}
+	if base, err := url.Parse(feedURL); err == nil {
+		feed.resolveLinks(base)
+	}
+
+	seen := make(map[string]bool, len(st.Seen))
+	for _, id := range st.Seen {
+		seen[id] = true
+	}
+	entries := make([]*FeedEntry, 0, len(feed.Entries))
+	for _, e := range feed.Entries {
+		if onlyNew && seen[e.ID] {
+			continue
+		}
+		if !since.IsZero() && (e.Published == nil || !e.Published.After(since)) {
+			continue
+		}
+		if !includeContent {
+			e.Content = ""
+		}
+		entries = append(entries, e)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i].Published, entries[j].Published
+		return a != nil && (b == nil || a.After(*b))
+	})
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	err = fs.feeds.update(feedURL, func(st *feedState) {
+		if modified {
+			st.ETag = resp.Header.Get("ETag")
+			st.LastModified = resp.Header.Get("Last-Modified")
+			st.body, st.contentType = nil, ""
+			if len(body) <= maxFeedBodySize {
+				st.body, st.contentType = body, contentType
+			}
+		}
+		if !onlyNew {
+			return
+		}
+		// Only what is returned counts as seen, newest first. The state is
+		// read again, another call may have added to it.
+		seen := make(map[string]bool, len(st.Seen))
+		for _, id := range st.Seen {
+			seen[id] = true
+		}
+		ids := make([]string, 0, len(entries)+len(st.Seen))
+		for _, e := range entries {
+			if !seen[e.ID] {
+				ids = append(ids, e.ID)
+				seen[e.ID] = true
+			}
+		}
+		st.Seen = append(ids, st.Seen...)
+		if len(st.Seen) > maxSeenEntries {
+			st.Seen = st.Seen[:maxSeenEntries]
+		}
+	})
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to save feed state: %v", err)), nil
+	}
+
+	feed.Entries = entries
+	payload, err := json.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(string(payload)), nil
+}
+
+// parseFeed detects the feed format and normalizes it
+func parseFeed(body []byte, contentType string) (*Feed, error) {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(body, []byte(utf8BOM)))
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return parseJSONFeed(trimmed)
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.Strict = false
+	decoder.CharsetReader = charset.NewReaderLabel
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return nil, errors.New("not an RSS, Atom or JSON feed")
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch start.Name.Local {
+		case "rss", "RDF":
+			var doc rssDocument
+			if err := decoder.DecodeElement(&doc, &start); err != nil {
+				return nil, err
+			}
+			return doc.normalize(), nil
+		case "feed":
+			var doc atomFeed
+			if err := decoder.DecodeElement(&doc, &start); err != nil {
+				return nil, err
+			}
+			return doc.normalize(), nil
+		}
+		return nil, fmt.Errorf("unsupported feed root element <%s>", start.Name.Local)
+	}
+}
+
+type rssDocument struct {
+	Channel struct {
+		Title string    `xml:"title"`
+		Link  string    `xml:"link"`
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+	// RSS 1.0 puts items next to the channel instead of inside it
+	Items []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Date        string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Author      string `xml:"author"`
+	Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Description string `xml:"description"`
+	Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+}
+
+func (d *rssDocument) normalize() *Feed {
+	feed := &Feed{
+		Title: strings.TrimSpace(d.Channel.Title),
+		Link:  strings.TrimSpace(d.Channel.Link),
+	}
+	for _, it := range append(d.Channel.Items, d.Items...) {
+		e := &FeedEntry{
+			ID:     firstNonEmpty(it.GUID, it.Link, it.Title),
+			Title:  strings.TrimSpace(it.Title),
+			Link:   strings.TrimSpace(it.Link),
+			Author: firstNonEmpty(it.Author, it.Creator),
+		}
+		if t, ok := parseDate(firstNonEmpty(it.PubDate, it.Date)); ok {
+			e.Published = &t
+		}
+		e.Summary = htmlToText(it.Description)
+		e.Content = htmlFragmentToMarkdown(firstNonEmpty(it.Content, it.Description))
+		feed.Entries = append(feed.Entries, e)
+	}
+	return feed
+}
+
+type atomFeed struct {
+	Title   string      `xml:"title"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+type atomText struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",innerxml"`
+}
+
+type atomEntry struct {
+	ID        string     `xml:"id"`
+	Title     atomText   `xml:"title"`
+	Links     []atomLink `xml:"link"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Authors   []struct {
+		Name string `xml:"name"`
+	} `xml:"author"`
+	Summary atomText `xml:"summary"`
+	Content atomText `xml:"content"`
+}
+
+// html returns the text construct as HTML, unescaping it where needed
+func (t atomText) html() string {
+	if t.Type == "xhtml" {
+		return t.Body
+	}
+	return xmlUnescape(t.Body)
+}
+
+func alternateLink(links []atomLink) string {
+	for _, l := range links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return l.Href
+		}
+	}
+	return ""
+}
+
+func (d *atomFeed) normalize() *Feed {
+	feed := &Feed{
+		Title: strings.TrimSpace(d.Title),
+		Link:  alternateLink(d.Links),
+	}
+	for _, en := range d.Entries {
+		e := &FeedEntry{
+			Title: htmlToText(en.Title.html()),
+			Link:  alternateLink(en.Links),
+		}
+		e.ID = firstNonEmpty(en.ID, e.Link, e.Title)
+		if len(en.Authors) > 0 {
+			e.Author = strings.TrimSpace(en.Authors[0].Name)
+		}
+		if t, ok := parseDate(firstNonEmpty(en.Published, en.Updated)); ok {
+			e.Published = &t
+		}
+		e.Summary = htmlToText(en.Summary.html())
+		e.Content = htmlFragmentToMarkdown(firstNonEmpty(en.Content.html(), en.Summary.html()))
+		feed.Entries = append(feed.Entries, e)
+	}
+	return feed
+}
+
+type jsonFeed struct {
+	Version     string `json:"version"`
+	Title       string `json:"title"`
+	HomePageURL string `json:"home_page_url"`
+	Items       []struct {
+		ID            interface{} `json:"id"`
+		URL           string      `json:"url"`
+		Title         string      `json:"title"`
+		ContentHTML   string      `json:"content_html"`
+		ContentText   string      `json:"content_text"`
+		Summary       string      `json:"summary"`
+		DatePublished string      `json:"date_published"`
+		DateModified  string      `json:"date_modified"`
+		Author        *struct {
+			Name string `json:"name"`
+		} `json:"author"`
+		Authors []struct {
+			Name string `json:"name"`
+		} `json:"authors"`
+	} `json:"items"`
+}
+
+func parseJSONFeed(body []byte) (*Feed, error) {
+	var doc jsonFeed
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(doc.Version, "https://jsonfeed.org/version/") {
+		return nil, errors.New("not a JSON Feed document")
+	}
+
+	feed := &Feed{
+		Title: doc.Title,
+		Link:  doc.HomePageURL,
+	}
+	for _, it := range doc.Items {
+		e := &FeedEntry{
+			ID:    firstNonEmpty(stringValue(it.ID), it.URL, it.Title),
+			Title: it.Title,
+			Link:  it.URL,
+		}
+		if len(it.Authors) > 0 {
+			e.Author = it.Authors[0].Name
+		} else if it.Author != nil {
+			e.Author = it.Author.Name
+		}
+		if t, ok := parseDate(firstNonEmpty(it.DatePublished, it.DateModified)); ok {
+			e.Published = &t
+		}
+		e.Summary = it.Summary
+		if it.ContentHTML != "" {
+			e.Content = htmlFragmentToMarkdown(it.ContentHTML)
+		} else {
+			e.Content = it.ContentText
+		}
+		if e.Summary == "" {
+			e.Summary = htmlToText(it.ContentHTML)
+			if e.Summary == "" {
+				e.Summary = it.ContentText
+			}
+		}
+		feed.Entries = append(feed.Entries, e)
+	}
+	return feed, nil
+}
+
+// resolveLinks makes relative feed and entry links absolute
+func (f *Feed) resolveLinks(base *url.URL) {
+	resolve := func(link string) string {
+		if link == "" {
+			return link
+		}
+		if u, err := base.Parse(link); err == nil {
+			return u.String()
+		}
+		return link
+	}
+	f.Link = resolve(f.Link)
+	for _, e := range f.Entries {
+		e.Link = resolve(e.Link)
+	}
+}
+
+func parseDate(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, false
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// htmlToText strips markup from an HTML fragment
+func htmlToText(fragment string) string {
+	if fragment == "" {
+		return ""
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(fragment))
+	if err != nil {
+		return strings.TrimSpace(fragment)
+	}
+	return strings.Join(strings.Fields(doc.Text()), " ")
+}
+
+// htmlFragmentToMarkdown converts an HTML fragment, falling back to its plain
+// text when it has no block structure the Markdown converter picks up.
+func htmlFragmentToMarkdown(fragment string) string {
+	if fragment == "" {
+		return ""
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(fragment))
+	if err != nil {
+		return strings.TrimSpace(fragment)
+	}
+	if md := strings.TrimSpace(htmlToMarkdown(doc)); md != "" {
+		return md
+	}
+	return strings.Join(strings.Fields(doc.Text()), " ")
+}
+
+func xmlUnescape(s string) string {
+	var out strings.Builder
+	decoder := xml.NewDecoder(strings.NewReader("<x>" + s + "</x>"))
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		if cd, ok := tok.(xml.CharData); ok {
+			out.Write(cd)
+		}
+	}
+	return out.String()
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			return v
+		}
+	}
+	return ""
+}
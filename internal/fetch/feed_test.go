@@ -0,0 +1,150 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dyike/MonoMCPHub/internal/fetch/config"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const rssFeed = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<rss version="2.0" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+<channel><title>Caf` + "\xe9" + ` news</title><link>https://example.com/</link>
+<item><title>Older</title><link>/older</link><guid>1</guid><pubDate>Mon, 02 Jan 2006 15:04:05 GMT</pubDate><description>old &lt;b&gt;post&lt;/b&gt;</description></item>
+<item><title>Newer</title><link>/newer</link><guid>2</guid><pubDate>Tue, 03 Jan 2006 15:04:05 GMT</pubDate>
+<dc:creator>ann</dc:creator><content:encoded><![CDATA[<h1>Heading</h1><p>Body text</p>]]></content:encoded></item>
+</channel></rss>`
+
+const atomFeedXML = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>Atom</title><link href="https://example.org/"/>
+<entry><id>urn:1</id><title type="html">A &lt;b&gt;bold&lt;/b&gt; title</title>
+<link rel="alternate" href="https://example.org/1"/><updated>2024-05-01T10:00:00Z</updated>
+<author><name>bob</name></author><summary>Short</summary></entry>
+</feed>`
+
+const jsonFeedDoc = `{"version": "https://jsonfeed.org/version/1.1", "title": "JSON",
+"items": [{"id": 7, "url": "https://example.net/7", "title": "Seven", "content_text": "plain", "date_published": "2024-01-02T03:04:05+01:00", "authors": [{"name": "cy"}]}]}`
+
+func TestParseFeed(t *testing.T) {
+	feed, err := parseFeed([]byte(rssFeed), "application/rss+xml")
+	if err != nil {
+		t.Fatalf("failed to parse RSS: %v", err)
+	}
+	if feed.Title != "Café news" || len(feed.Entries) != 2 {
+		t.Fatalf("unexpected RSS feed: %+v", feed)
+	}
+	newer := feed.Entries[1]
+	if newer.ID != "2" || newer.Author != "ann" || newer.Published == nil || newer.Published.Day() != 3 {
+		t.Errorf("unexpected RSS entry: %+v", newer)
+	}
+	if !strings.Contains(newer.Content, "Body text") || strings.Contains(newer.Content, "<p>") {
+		t.Errorf("expected markdown content, got %q", newer.Content)
+	}
+	if feed.Entries[0].Summary != "old post" {
+		t.Errorf("expected summary markup to be stripped, got %q", feed.Entries[0].Summary)
+	}
+
+	feed, err = parseFeed([]byte(atomFeedXML), "application/atom+xml")
+	if err != nil {
+		t.Fatalf("failed to parse Atom: %v", err)
+	}
+	if e := feed.Entries[0]; e.ID != "urn:1" || e.Title != "A bold title" || e.Link != "https://example.org/1" || e.Author != "bob" || e.Published == nil {
+		t.Errorf("unexpected Atom entry: %+v", e)
+	}
+
+	feed, err = parseFeed([]byte(jsonFeedDoc), "application/feed+json")
+	if err != nil {
+		t.Fatalf("failed to parse JSON Feed: %v", err)
+	}
+	if e := feed.Entries[0]; e.ID != "7" || e.Content != "plain" || e.Author != "cy" || e.Published == nil {
+		t.Errorf("unexpected JSON Feed entry: %+v", e)
+	}
+
+	if _, err := parseFeed([]byte("<html><body>nope</body></html>"), "text/html"); err == nil {
+		t.Errorf("expected HTML to be rejected")
+	}
+}
+
+func TestFetchFeedOnlyNew(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(rssFeed))
+	}))
+	defer ts.Close()
+
+	cfg := config.NewFetchConfig()
+	cfg.DataPath = t.TempDir()
+	fs := newTestService(cfg)
+
+	call := func(args map[string]interface{}) *Feed {
+		t.Helper()
+		args["url"] = ts.URL
+		result, err := fs.handleFeed(context.Background(), newFetchRequest(args))
+		if err != nil || result.IsError {
+			t.Fatalf("fetch_feed failed: %v %v", err, result)
+		}
+		text := result.Content[0].(mcp.TextContent).Text
+		var feed Feed
+		if err := json.Unmarshal([]byte(text), &feed); err != nil {
+			return nil
+		}
+		return &feed
+	}
+
+	feed := call(map[string]interface{}{"only_new": true, "since": "2006-01-03"})
+	if feed == nil || len(feed.Entries) != 1 || feed.Entries[0].Link != ts.URL+"/newer" {
+		t.Fatalf("unexpected first result: %+v", feed)
+	}
+	// The entry left out by since was never returned, so it is still new.
+	// It comes from the cached body, the server answers 304.
+	if feed := call(map[string]interface{}{"only_new": true}); feed == nil || len(feed.Entries) != 1 || feed.Entries[0].ID != "1" {
+		t.Errorf("expected the remaining entry after a 304, got %+v", feed)
+	}
+	if feed := call(map[string]interface{}{"only_new": true}); feed == nil || len(feed.Entries) != 0 {
+		t.Errorf("expected no new entries, got %+v", feed)
+	}
+
+	// Seen entries survive a restart, a fresh service has no cached body
+	// so it fetches the whole feed again.
+	fs = newTestService(cfg)
+	if feed := call(map[string]interface{}{"only_new": true}); feed == nil || len(feed.Entries) != 0 {
+		t.Errorf("expected no new entries after a restart, got %+v", feed)
+	}
+	feed = call(map[string]interface{}{})
+	if feed == nil || len(feed.Entries) != 2 || feed.Entries[0].ID != "2" {
+		t.Errorf("expected full feed newest first, got %+v", feed)
+	}
+	if requests != 5 {
+		t.Errorf("expected 5 requests, got %d", requests)
+	}
+}
+
+func TestFeedReaderBodies(t *testing.T) {
+	fr := newFeedReader(newStore(t.TempDir()))
+	for i := 0; i <= maxFeedBodies; i++ {
+		if err := fr.update(fmt.Sprint("https://example.com/", i), func(st *feedState) {
+			st.body = []byte("feed")
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if st := fr.state("https://example.com/0"); st.body != nil {
+		t.Errorf("expected the oldest body to be dropped")
+	}
+	if st := fr.state(fmt.Sprint("https://example.com/", maxFeedBodies)); st.body == nil {
+		t.Errorf("expected the newest body to be kept")
+	}
+}
@@ -0,0 +1,223 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/dyike/MonoMCPHub/internal/fetch/config"
+	"github.com/dyike/MonoMCPHub/pkg/httpclient"
+)
+
+var (
+	errBlockedAddress  = errors.New("destination address is not allowed")
+	errBodyTooLarge    = errors.New("response body exceeds the maximum size")
+	errUnsupportedURL  = errors.New("only http and https URLs can be fetched")
+	sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+)
+
+// guard keeps outbound requests away from internal networks. Addresses are
+// checked when the connection is made, after DNS resolution, so a public name
+// that resolves to a private address is blocked as well.
+type guard struct {
+	allowPrivate bool
+	hosts        map[string]bool
+	prefixes     []netip.Prefix
+	maxRedirects int
+	maxBodySize  int64
+	// proxied is set when requests go through a proxy, which resolves the
+	// target names itself
+	proxied bool
+	// lookup resolves host names for vetURL
+	lookup func(ctx context.Context, host string) ([]netip.Addr, error)
+}
+
+func newGuard(cfg *config.FetchConfig) *guard {
+	g := &guard{
+		allowPrivate: cfg.AllowPrivateNetworks,
+		hosts:        make(map[string]bool),
+		maxRedirects: cfg.MaxRedirects,
+		maxBodySize:  cfg.MaxBodySize,
+		lookup: func(ctx context.Context, host string) ([]netip.Addr, error) {
+			return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		},
+	}
+	for _, h := range cfg.AllowedHosts {
+		if prefix, err := netip.ParsePrefix(h); err == nil {
+			g.prefixes = append(g.prefixes, prefix.Masked())
+			continue
+		}
+		if addr, err := netip.ParseAddr(h); err == nil {
+			g.prefixes = append(g.prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		g.hosts[strings.ToLower(h)] = true
+	}
+	// Behind a proxy only the proxy itself is dialed, and it resolves the
+	// target names, so those are looked up and vetted up front instead.
+	for _, h := range httpclient.Resolve(cfg.HTTP).ProxyHosts() {
+		g.hosts[strings.ToLower(h)] = true
+		g.proxied = true
+	}
+	return g
+}
+
+// newClient returns an HTTP client whose connections and redirects go through
+// the guard, using the shared proxy and CA settings.
+func (g *guard) newClient(cfg *config.FetchConfig) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   time.Duration(cfg.ConnectTimeout) * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	transport, err := httpclient.NewTransport(httpclient.Resolve(cfg.HTTP))
+	if err != nil {
+		slog.Error("Invalid outbound HTTP settings, connecting directly", "error", err)
+		transport = &http.Transport{}
+	}
+	transport.DialContext = g.dialContext(dialer)
+	transport.ForceAttemptHTTP2 = true
+	transport.MaxIdleConns = 100
+	transport.IdleConnTimeout = 90 * time.Second
+	transport.TLSHandshakeTimeout = time.Duration(cfg.ConnectTimeout) * time.Second
+	transport.ResponseHeaderTimeout = time.Duration(cfg.ReadTimeout) * time.Second
+	transport.ExpectContinueTimeout = 1 * time.Second
+	return &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(cfg.Timeout) * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return g.checkRedirect(req, via, g.maxRedirects)
+		},
+	}
+}
+
+func (g *guard) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if g.allowPrivate || g.hosts[strings.ToLower(host)] {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		guarded := *dialer
+		guarded.Control = func(network, address string, _ syscall.RawConn) error {
+			ipPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !g.allowedAddr(ipPort.Addr()) {
+				return fmt.Errorf("%w: %s resolves to %s", errBlockedAddress, host, ipPort.Addr())
+			}
+			return nil
+		}
+		return guarded.DialContext(ctx, network, addr)
+	}
+}
+
+// checkURL rejects non-HTTP schemes and literal addresses that the dialer
+// would refuse anyway, so the error names the URL instead of a socket.
+func (g *guard) checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%w: %s", errUnsupportedURL, u.Redacted())
+	}
+	host := strings.ToLower(u.Hostname())
+	if g.allowPrivate || g.hosts[host] {
+		return nil
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: %s", errBlockedAddress, host)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !g.allowedAddr(addr) {
+		return fmt.Errorf("%w: %s", errBlockedAddress, host)
+	}
+	return nil
+}
+
+// vetURL is checkURL plus a lookup of host names, for requests the guarded
+// dialer does not see, such as the ones Chrome makes. A name that resolves to
+// a blocked address is rejected; a name that changes its address between this
+// lookup and the connection is not caught.
+func (g *guard) vetURL(ctx context.Context, u *url.URL) error {
+	if err := g.checkURL(u); err != nil {
+		return err
+	}
+	host := strings.ToLower(u.Hostname())
+	if g.allowPrivate || g.hosts[host] {
+		return nil
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return nil
+	}
+	addrs, err := g.lookup(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !g.allowedAddr(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", errBlockedAddress, host, addr)
+		}
+	}
+	return nil
+}
+
+// checkTarget checks the URL of a request before it is sent. Behind a proxy
+// the dialer only sees the proxy, so the host name is resolved and vetted here.
+func (g *guard) checkTarget(ctx context.Context, u *url.URL) error {
+	if g.proxied {
+		return g.vetURL(ctx, u)
+	}
+	return g.checkURL(u)
+}
+
+// checkRedirect validates every redirect target and caps the redirect chain
+func (g *guard) checkRedirect(req *http.Request, via []*http.Request, maxRedirects int) error {
+	if maxRedirects > g.maxRedirects {
+		maxRedirects = g.maxRedirects
+	}
+	if len(via) > maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	return g.checkTarget(req.Context(), req.URL)
+}
+
+func (g *guard) allowedAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range g.prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	if g.allowPrivate {
+		return true
+	}
+	return !(addr.IsLoopback() ||
+		addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() ||
+		addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() ||
+		addr.IsMulticast() ||
+		addr.IsUnspecified() ||
+		sharedAddressSpace.Contains(addr))
+}
+
+// readBody reads a response body, failing once it grows past the size limit
+func (g *guard) readBody(r io.Reader) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, g.maxBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > g.maxBodySize {
+		return nil, fmt.Errorf("%w of %d bytes", errBodyTooLarge, g.maxBodySize)
+	}
+	return body, nil
+}
@@ -0,0 +1,143 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dyike/MonoMCPHub/internal/fetch/config"
+	"github.com/dyike/MonoMCPHub/pkg/httpclient"
+)
+
+func TestGuardAllowedAddr(t *testing.T) {
+	cfg := config.NewFetchConfig()
+	cfg.AllowedHosts = []string{"10.1.0.0/16", "192.168.1.10"}
+	g := newGuard(cfg)
+
+	cases := map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"10.0.0.1":         false,
+		"172.16.5.4":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"fe80::1":          false,
+		"100.64.0.1":       false,
+		"0.0.0.0":          false,
+		"::ffff:127.0.0.1": false,
+		"10.1.2.3":         true,
+		"192.168.1.10":     true,
+	}
+	for addr, want := range cases {
+		if got := g.allowedAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("allowedAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestGuardCheckURL(t *testing.T) {
+	g := newGuard(config.NewFetchConfig())
+	for _, raw := range []string{"file:///etc/passwd", "ftp://example.com/", "http://localhost:8080/", "http://169.254.169.254/latest/meta-data/", "http://[::1]/"} {
+		u, _ := url.Parse(raw)
+		if err := g.checkURL(u); err == nil {
+			t.Errorf("expected %s to be rejected", raw)
+		}
+	}
+	u, _ := url.Parse("https://example.com/")
+	if err := g.checkURL(u); err != nil {
+		t.Errorf("expected public URL to be allowed: %v", err)
+	}
+}
+
+func TestGuardVetURL(t *testing.T) {
+	g := newGuard(config.NewFetchConfig())
+	g.lookup = func(ctx context.Context, host string) ([]netip.Addr, error) {
+		if host == "internal.example.com" {
+			return []netip.Addr{netip.MustParseAddr("93.184.216.34"), netip.MustParseAddr("10.0.0.1")}, nil
+		}
+		return []netip.Addr{netip.MustParseAddr("93.184.216.34")}, nil
+	}
+	u, _ := url.Parse("http://internal.example.com/")
+	if err := g.vetURL(context.Background(), u); !errors.Is(err, errBlockedAddress) {
+		t.Errorf("expected name resolving to a private address to be blocked, got %v", err)
+	}
+	u, _ = url.Parse("https://example.com/")
+	if err := g.vetURL(context.Background(), u); err != nil {
+		t.Errorf("expected public name to be allowed: %v", err)
+	}
+}
+
+func TestGuardChecksNamesBehindProxy(t *testing.T) {
+	cfg := config.NewFetchConfig()
+	cfg.HTTP = &httpclient.Config{Proxy: "http://proxy.example.com:3128"}
+	g := newGuard(cfg)
+	g.lookup = func(ctx context.Context, host string) ([]netip.Addr, error) {
+		return []netip.Addr{netip.MustParseAddr("10.0.0.1")}, nil
+	}
+	u, _ := url.Parse("http://intranet.example.com/")
+	if err := g.checkTarget(context.Background(), u); !errors.Is(err, errBlockedAddress) {
+		t.Errorf("expected name resolving to a private address to be blocked behind a proxy, got %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "http://intranet.example.com/", nil)
+	if err := g.checkRedirect(req, []*http.Request{req}, 10); !errors.Is(err, errBlockedAddress) {
+		t.Errorf("expected redirect to a private address to be blocked behind a proxy, got %v", err)
+	}
+
+	// Without a proxy the dialer checks the resolved address.
+	g = newGuard(config.NewFetchConfig())
+	g.lookup = func(ctx context.Context, host string) ([]netip.Addr, error) {
+		t.Fatalf("unexpected lookup of %s", host)
+		return nil, nil
+	}
+	if err := g.checkTarget(context.Background(), u); err != nil {
+		t.Errorf("expected URL to pass, got %v", err)
+	}
+}
+
+func TestGuardBlocksLoopback(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secret"))
+	}))
+	defer ts.Close()
+
+	fs := NewFetchService(context.Background(), nil)
+	if _, _, err := fs.get(context.Background(), ts.URL); !errors.Is(err, errBlockedAddress) {
+		t.Errorf("expected loopback fetch to be blocked, got %v", err)
+	}
+}
+
+func TestGuardRedirectAndSize(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/metadata":
+			http.Redirect(w, r, "http://169.254.169.254/latest/meta-data/", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/large":
+			w.Write([]byte(strings.Repeat("x", 2048)))
+		}
+	}))
+	defer ts.Close()
+
+	cfg := config.NewFetchConfig()
+	cfg.MaxBodySize = 1024
+	cfg.MaxRedirects = 3
+	fs := newTestService(cfg)
+
+	if _, _, err := fs.get(context.Background(), ts.URL+"/metadata"); !errors.Is(err, errBlockedAddress) {
+		t.Errorf("expected redirect to metadata address to be blocked, got %v", err)
+	}
+	if _, _, err := fs.get(context.Background(), ts.URL+"/loop"); err == nil || !strings.Contains(err.Error(), "stopped after 3 redirects") {
+		t.Errorf("expected redirect cap to apply, got %v", err)
+	}
+	if _, _, err := fs.get(context.Background(), ts.URL+"/large"); !errors.Is(err, errBodyTooLarge) {
+		t.Errorf("expected oversized body to be rejected, got %v", err)
+	}
+}
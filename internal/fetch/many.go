@@ -0,0 +1,200 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	maxManyURLs        = 50
+	maxManyConcurrency = 16
+)
+
+// fetchURLArgs are the fetch_url arguments fetch_many passes through per URL
+var fetchURLArgs = []string{"as_html", "jsonpath", "render", "wait_selector", "metadata", "profile"}
+
+func (fs *FetchService) newManyTool() mcp.Tool {
+	return mcp.NewTool("fetch_many",
+		mcp.WithDescription("Fetch several URLs concurrently with the same options as fetch_url. Each URL gets its own section in the result and a failing URL does not fail the others"),
+		mcp.WithArray("urls",
+			mcp.Required(),
+			mcp.Description(fmt.Sprintf("The URLs to fetch, at most %d", maxManyURLs)),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+		),
+		mcp.WithBoolean("as_html",
+			mcp.Description("Return the content as HTML"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithString("jsonpath",
+			mcp.Description("JSONPath expression used to filter JSON responses"),
+		),
+		mcp.WithString("render",
+			mcp.Description("Render pages in a headless browser: auto renders only pages that come back nearly empty"),
+			mcp.DefaultString(renderAuto),
+			mcp.Enum(renderAuto, renderAlways, renderNever),
+		),
+		mcp.WithString("wait_selector",
+			mcp.Description("CSS selector to wait for when rendering"),
+		),
+		mcp.WithBoolean("metadata",
+			mcp.Description("Start Markdown output with a header of the page metadata"),
+			mcp.DefaultBool(true),
+		),
+		profileArg(fs.profileNames()),
+		mcp.WithNumber("concurrency",
+			mcp.Description(fmt.Sprintf("Number of URLs fetched at the same time, at most %d", maxManyConcurrency)),
+			mcp.DefaultNumber(4),
+		),
+		mcp.WithNumber("per_host",
+			mcp.Description("Number of concurrent requests to the same host"),
+			mcp.DefaultNumber(2),
+		),
+		mcp.WithNumber("timeout",
+			mcp.Description("Time budget in seconds for the whole batch, URLs not done by then are reported as timed out"),
+			mcp.DefaultNumber(60),
+		),
+	)
+}
+
+// manyResult is the outcome of fetching one URL of a batch
+type manyResult struct {
+	url    string
+	result *mcp.CallToolResult
+	err    error
+}
+
+func (fs *FetchService) handleFetchMany(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+
+	rawURLs, _ := args["urls"].([]interface{})
+	urls := make([]string, 0, len(rawURLs))
+	for _, v := range rawURLs {
+		if s, ok := v.(string); ok && s != "" {
+			urls = append(urls, s)
+		}
+	}
+	if len(urls) == 0 {
+		return mcp.NewToolResultError("urls must be a non-empty list of strings"), nil
+	}
+	if len(urls) > maxManyURLs {
+		return mcp.NewToolResultError(fmt.Sprintf("At most %d URLs can be fetched at once", maxManyURLs)), nil
+	}
+	concurrency := min(max(intArg(args, "concurrency", 4), 1), maxManyConcurrency)
+	perHost := max(intArg(args, "per_host", 2), 1)
+	budget := time.Duration(max(intArg(args, "timeout", 60), 1)) * time.Second
+
+	ctx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+
+	results := make([]manyResult, len(urls))
+	sem := make(chan struct{}, concurrency)
+	var (
+		mu    sync.Mutex
+		hosts = make(map[string]chan struct{})
+		done  int
+		wg    sync.WaitGroup
+	)
+	hostSem := func(rawURL string) chan struct{} {
+		host := rawURL
+		if u, err := url.Parse(rawURL); err == nil {
+			host = strings.ToLower(u.Host)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		s, ok := hosts[host]
+		if !ok {
+			s = make(chan struct{}, perHost)
+			hosts[host] = s
+		}
+		return s
+	}
+
+	for i, u := range urls {
+		wg.Add(1)
+		go func(i int, u string) {
+			defer wg.Done()
+			results[i] = manyResult{url: u}
+			defer func() {
+				mu.Lock()
+				done++
+				progress := done
+				mu.Unlock()
+				sendProgress(ctx, request, float64(progress), float64(len(urls)))
+			}()
+
+			// Taking the host slot first keeps a busy host from holding global slots.
+			hs := hostSem(u)
+			select {
+			case hs <- struct{}{}:
+				defer func() { <-hs }()
+			case <-ctx.Done():
+				results[i].err = ctx.Err()
+				return
+			}
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i].err = ctx.Err()
+				return
+			}
+
+			var req mcp.CallToolRequest
+			req.Params.Name = "fetch_url"
+			req.Params.Arguments = map[string]interface{}{"url": u}
+			for _, name := range fetchURLArgs {
+				if v, ok := args[name]; ok {
+					req.Params.Arguments[name] = v
+				}
+			}
+			results[i].result, results[i].err = fs.handleFetchURL(ctx, req)
+		}(i, u)
+	}
+	wg.Wait()
+
+	return manyToolResult(results), nil
+}
+
+// manyToolResult lays out the batch as one text section per URL, followed by
+// any non-text content the URL produced.
+func manyToolResult(results []manyResult) *mcp.CallToolResult {
+	out := &mcp.CallToolResult{}
+	failed := 0
+	for _, r := range results {
+		var text strings.Builder
+		fmt.Fprintf(&text, "## %s\n\n", r.url)
+
+		var extra []mcp.Content
+		switch {
+		case errors.Is(r.err, context.DeadlineExceeded):
+			failed++
+			text.WriteString("Error: the time budget of the batch ran out\n")
+		case r.err != nil:
+			failed++
+			fmt.Fprintf(&text, "Error: %v\n", r.err)
+		default:
+			if r.result.IsError {
+				failed++
+				text.WriteString("Error: ")
+			}
+			for _, c := range r.result.Content {
+				if tc, ok := c.(mcp.TextContent); ok {
+					text.WriteString(tc.Text)
+				} else {
+					extra = append(extra, c)
+				}
+			}
+		}
+		out.Content = append(out.Content, mcp.TextContent{Type: "text", Text: strings.TrimRight(text.String(), "\n") + "\n"})
+		out.Content = append(out.Content, extra...)
+	}
+	out.IsError = failed == len(results)
+	return out
+}
@@ -0,0 +1,85 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dyike/MonoMCPHub/internal/fetch/config"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestFetchMany(t *testing.T) {
+	var active, peak atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := active.Add(1)
+		defer active.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		switch r.URL.Path {
+		case "/missing":
+			http.NotFound(w, r)
+			return
+		case "/slow":
+			select {
+			case <-time.After(5 * time.Second):
+			case <-r.Context().Done():
+			}
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte("page " + r.URL.Path))
+	}))
+	defer srv.Close()
+
+	fs := newTestService(config.NewFetchConfig())
+	urls := []interface{}{srv.URL + "/a", srv.URL + "/missing", srv.URL + "/b", srv.URL + "/c"}
+	result, err := fs.handleFetchMany(context.Background(), newFetchRequest(map[string]interface{}{
+		"urls":     urls,
+		"per_host": float64(2),
+	}))
+	if err != nil || result.IsError {
+		t.Fatalf("fetch_many failed: %v %v", err, result)
+	}
+	if len(result.Content) != len(urls) {
+		t.Fatalf("expected %d sections, got %d", len(urls), len(result.Content))
+	}
+	for i, want := range []string{"page /a", "HTTP error", "page /b", "page /c"} {
+		text := result.Content[i].(mcp.TextContent).Text
+		if !strings.HasPrefix(text, "## "+urls[i].(string)+"\n") || !strings.Contains(text, want) {
+			t.Errorf("section %d: expected %q, got %q", i, want, text)
+		}
+	}
+	if p := peak.Load(); p > 2 {
+		t.Errorf("per host limit of 2 exceeded: %d concurrent requests", p)
+	}
+
+	start := time.Now()
+	result, err = fs.handleFetchMany(context.Background(), newFetchRequest(map[string]interface{}{
+		"urls":    []interface{}{srv.URL + "/slow"},
+		"timeout": float64(1),
+	}))
+	if err != nil {
+		t.Fatalf("fetch_many failed: %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Errorf("time budget not enforced, took %v", time.Since(start))
+	}
+	if !result.IsError || !strings.Contains(result.Content[0].(mcp.TextContent).Text, "time budget") {
+		t.Errorf("expected a time budget error, got %v", result.Content)
+	}
+
+	result, _ = fs.handleFetchMany(context.Background(), newFetchRequest(map[string]interface{}{"urls": []interface{}{}}))
+	if !result.IsError {
+		t.Error("expected an error for an empty list")
+	}
+}
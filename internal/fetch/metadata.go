@@ -0,0 +1,220 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// feedTypes are the link types advertised for RSS, Atom and JSON feeds
+var feedTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+	"application/json":      true,
+}
+
+// PageMetadata is what a page says about itself in its head and JSON-LD
+type PageMetadata struct {
+	Title       string            `json:"title,omitempty"`
+	Description string            `json:"description,omitempty"`
+	Canonical   string            `json:"canonical,omitempty"`
+	Author      string            `json:"author,omitempty"`
+	Published   string            `json:"published,omitempty"`
+	Modified    string            `json:"modified,omitempty"`
+	Language    string            `json:"language,omitempty"`
+	SiteName    string            `json:"site_name,omitempty"`
+	Image       string            `json:"image,omitempty"`
+	OpenGraph   map[string]string `json:"open_graph,omitempty"`
+	Twitter     map[string]string `json:"twitter,omitempty"`
+	JSONLD      []interface{}     `json:"json_ld,omitempty"`
+	Feeds       []FeedLink        `json:"feeds,omitempty"`
+}
+
+// FeedLink is a feed advertised with <link rel="alternate">
+type FeedLink struct {
+	URL   string `json:"url"`
+	Type  string `json:"type"`
+	Title string `json:"title,omitempty"`
+}
+
+// extractMetadata collects page metadata, preferring explicit meta tags over
+// OpenGraph and Twitter cards, and those over JSON-LD and the document itself.
+func extractMetadata(doc *goquery.Document, base *url.URL) *PageMetadata {
+	meta := make(map[string]string)
+	og := make(map[string]string)
+	twitter := make(map[string]string)
+	doc.Find("meta").Each(func(i int, s *goquery.Selection) {
+		content, ok := s.Attr("content")
+		if !ok {
+			return
+		}
+		content = strings.TrimSpace(content)
+		key := strings.ToLower(firstNonEmpty(s.AttrOr("property", ""), s.AttrOr("name", ""), s.AttrOr("http-equiv", "")))
+		switch {
+		case key == "" || content == "":
+		case strings.HasPrefix(key, "og:"):
+			if _, ok := og[key[3:]]; !ok {
+				og[key[3:]] = content
+			}
+		case strings.HasPrefix(key, "twitter:"):
+			if _, ok := twitter[key[8:]]; !ok {
+				twitter[key[8:]] = content
+			}
+		default:
+			if _, ok := meta[key]; !ok {
+				meta[key] = content
+			}
+		}
+	})
+
+	var ld []interface{}
+	doc.Find(`script[type="application/ld+json"]`).Each(func(i int, s *goquery.Selection) {
+		var v interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &v); err == nil {
+			ld = append(ld, v)
+		}
+	})
+
+	resolve := func(ref string) string {
+		if ref == "" || base == nil {
+			return ref
+		}
+		if u, err := base.Parse(ref); err == nil {
+			return u.String()
+		}
+		return ref
+	}
+
+	m := &PageMetadata{
+		Title:       firstNonEmpty(og["title"], twitter["title"], doc.Find("title").First().Text(), jsonLDString(ld, "headline", "name")),
+		Description: firstNonEmpty(meta["description"], og["description"], twitter["description"], jsonLDString(ld, "description")),
+		Canonical:   resolve(firstNonEmpty(doc.Find(`link[rel="canonical"]`).AttrOr("href", ""), og["url"])),
+		Author:      firstNonEmpty(meta["author"], meta["article:author"], meta["dc.creator"], jsonLDString(ld, "author")),
+		Published:   firstNonEmpty(meta["article:published_time"], meta["date"], meta["pubdate"], meta["dc.date"], jsonLDString(ld, "datePublished")),
+		Modified:    firstNonEmpty(meta["article:modified_time"], og["updated_time"], jsonLDString(ld, "dateModified")),
+		Language:    firstNonEmpty(doc.Find("html").AttrOr("lang", ""), meta["content-language"], og["locale"]),
+		SiteName:    firstNonEmpty(og["site_name"], twitter["site"], jsonLDString(ld, "publisher")),
+		Image:       resolve(firstNonEmpty(og["image"], twitter["image"], jsonLDString(ld, "image"))),
+		JSONLD:      ld,
+	}
+	if len(og) > 0 {
+		m.OpenGraph = og
+	}
+	if len(twitter) > 0 {
+		m.Twitter = twitter
+	}
+
+	doc.Find(`link[rel~="alternate"]`).Each(func(i int, s *goquery.Selection) {
+		typ := strings.ToLower(strings.TrimSpace(s.AttrOr("type", "")))
+		href := s.AttrOr("href", "")
+		if !feedTypes[typ] || href == "" {
+			return
+		}
+		m.Feeds = append(m.Feeds, FeedLink{URL: resolve(href), Type: typ, Title: strings.TrimSpace(s.AttrOr("title", ""))})
+	})
+	return m
+}
+
+// jsonLDString returns the first string found under one of keys in the JSON-LD
+// objects, looking into @graph and taking the name of nested objects.
+func jsonLDString(objects []interface{}, keys ...string) string {
+	for _, key := range keys {
+		for _, obj := range objects {
+			if s := findJSONLD(obj, key); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+func findJSONLD(v interface{}, key string) string {
+	switch v := v.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if s := findJSONLD(item, key); s != "" {
+				return s
+			}
+		}
+	case map[string]interface{}:
+		if s := jsonLDValue(v[key]); s != "" {
+			return s
+		}
+		return findJSONLD(v["@graph"], key)
+	}
+	return ""
+}
+
+// jsonLDValue flattens a JSON-LD property to a string
+func jsonLDValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case []interface{}:
+		if len(v) > 0 {
+			return jsonLDValue(v[0])
+		}
+	case map[string]interface{}:
+		return firstNonEmpty(jsonLDValue(v["name"]), jsonLDValue(v["url"]), jsonLDValue(v["@id"]))
+	}
+	return ""
+}
+
+// header renders the citation fields as a front matter block for fetch_url
+func (m *PageMetadata) header() string {
+	fields := []struct{ key, value string }{
+		{"title", m.Title},
+		{"url", m.Canonical},
+		{"author", m.Author},
+		{"published", m.Published},
+		{"language", m.Language},
+		{"description", m.Description},
+	}
+	var b strings.Builder
+	for _, f := range fields {
+		if v := strings.Join(strings.Fields(f.value), " "); v != "" {
+			fmt.Fprintf(&b, "%s: %s\n", f.key, strconv.Quote(v))
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return "---\n" + b.String() + "---\n\n"
+}
+
+func newMetadataTool() mcp.Tool {
+	return mcp.NewTool("fetch_metadata",
+		mcp.WithDescription("Get the metadata of a web page: title, description, canonical URL, author, dates, language, OpenGraph and Twitter card fields, JSON-LD objects and feed links"),
+		mcp.WithString("url",
+			mcp.Description("The URL of the page"),
+		),
+		mcp.WithString("html",
+			mcp.Description("Raw HTML to read metadata from instead of fetching a URL"),
+		),
+	)
+}
+
+func (fs *FetchService) handleMetadata(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	html, base, err := fs.extractSource(ctx, request.Params.Arguments)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to parse HTML: %v", err)), nil
+	}
+
+	payload, err := json.MarshalIndent(extractMetadata(doc, base), "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(string(payload)), nil
+}
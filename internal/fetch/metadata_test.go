@@ -0,0 +1,63 @@
+package fetch
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const articleHTML = `<!DOCTYPE html><html lang="en-GB"><head>
+<title>Fallback title</title>
+<meta name="description" content="A short summary.">
+<meta property="og:title" content="The Article">
+<meta property="og:site_name" content="Example News">
+<meta property="og:image" content="/img/cover.png">
+<meta name="twitter:card" content="summary_large_image">
+<meta property="article:published_time" content="2024-03-01T08:00:00Z">
+<link rel="canonical" href="/articles/42">
+<link rel="alternate" type="application/rss+xml" title="RSS" href="/feed.xml">
+<link rel="alternate" hreflang="de" href="/de/articles/42">
+<script type="application/ld+json">{"@context": "https://schema.org", "@graph": [
+  {"@type": "NewsArticle", "author": [{"@type": "Person", "name": "Jane Doe"}], "dateModified": "2024-03-02"}
+]}</script>
+</head><body><p>Body</p></body></html>`
+
+func TestExtractMetadata(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(articleHTML))
+	if err != nil {
+		t.Fatalf("failed to parse HTML: %v", err)
+	}
+	base, _ := url.Parse("https://news.example.com/articles/42?utm=x")
+	m := extractMetadata(doc, base)
+
+	checks := map[string][2]string{
+		"title":       {m.Title, "The Article"},
+		"description": {m.Description, "A short summary."},
+		"canonical":   {m.Canonical, "https://news.example.com/articles/42"},
+		"author":      {m.Author, "Jane Doe"},
+		"published":   {m.Published, "2024-03-01T08:00:00Z"},
+		"modified":    {m.Modified, "2024-03-02"},
+		"language":    {m.Language, "en-GB"},
+		"site_name":   {m.SiteName, "Example News"},
+		"image":       {m.Image, "https://news.example.com/img/cover.png"},
+		"twitter":     {m.Twitter["card"], "summary_large_image"},
+	}
+	for name, c := range checks {
+		if c[0] != c[1] {
+			t.Errorf("%s = %q, want %q", name, c[0], c[1])
+		}
+	}
+	if len(m.JSONLD) != 1 {
+		t.Errorf("expected one JSON-LD object, got %d", len(m.JSONLD))
+	}
+	if len(m.Feeds) != 1 || m.Feeds[0].URL != "https://news.example.com/feed.xml" || m.Feeds[0].Title != "RSS" {
+		t.Errorf("unexpected feeds: %+v", m.Feeds)
+	}
+
+	header := m.header()
+	if !strings.HasPrefix(header, "---\ntitle: \"The Article\"\nurl: ") || !strings.Contains(header, `author: "Jane Doe"`) {
+		t.Errorf("unexpected header: %q", header)
+	}
+}
@@ -0,0 +1,341 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/cdproto/storage"
+	"github.com/chromedp/chromedp"
+	browser "github.com/dyike/MonoMCPHub/internal/browser/service"
+	"github.com/dyike/MonoMCPHub/internal/fetch/config"
+	"github.com/mark3labs/mcp-go/mcp"
+	"golang.org/x/net/publicsuffix"
+)
+
+// chromeImportTimeout bounds reading cookies out of a Chrome profile
+const chromeImportTimeout = 30 * time.Second
+
+// fetchProfile is a configured profile with its own client and cookie jar
+type fetchProfile struct {
+	name   string
+	cred   config.Credential
+	client *http.Client
+
+	// load creates the jar, which may import cookies from Chrome, once
+	load sync.Once
+	err  error
+}
+
+// apply attaches the profile's credentials to a request, failing for hosts
+// the profile is not configured for so neither its credentials nor its
+// cookies reach them
+func (p *fetchProfile) apply(req *http.Request) error {
+	if !p.cred.Allows(req.URL) {
+		return fmt.Errorf("fetch profile %s may not be used for %s", p.name, req.URL.Redacted())
+	}
+	applyCredential(req, p.cred)
+	return nil
+}
+
+// applyCredential sets the headers, bearer token and basic auth of cred on req
+func applyCredential(req *http.Request, cred config.Credential) {
+	for k, v := range cred.Headers {
+		req.Header.Set(k, v)
+	}
+	if cred.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+cred.BearerToken)
+	}
+	if cred.Username != "" || cred.Password != "" {
+		req.SetBasicAuth(cred.Username, cred.Password)
+	}
+}
+
+// dropCredential removes the headers cred sets from a redirect that leaves
+// the host of the original request, so they are only ever sent to that host
+func dropCredential(req *http.Request, via []*http.Request, cred config.Credential) {
+	if len(via) == 0 || strings.EqualFold(req.URL.Host, via[0].URL.Host) {
+		return
+	}
+	for k := range cred.Headers {
+		req.Header.Del(k)
+	}
+	if cred.BearerToken != "" || cred.Username != "" || cred.Password != "" {
+		req.Header.Del("Authorization")
+	}
+}
+
+// profileArg describes the fetch_url profile argument, listing the configured
+// profiles by name only.
+func profileArg(names []string) mcp.ToolOption {
+	opts := []mcp.PropertyOption{
+		mcp.Description("Name of a configured fetch profile whose cookies and credentials are used for the request"),
+	}
+	if len(names) > 0 {
+		opts = append(opts, mcp.Enum(names...))
+	}
+	return mcp.WithString("profile", opts...)
+}
+
+// profileNames returns the configured profile names in a stable order
+func (fs *FetchService) profileNames() []string {
+	names := make([]string, 0, len(fs.config.Profiles))
+	for name := range fs.config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// profile returns the named profile, creating its client and loading its
+// cookie jar on first use. An empty name selects no profile.
+func (fs *FetchService) profile(ctx context.Context, name string) (*fetchProfile, error) {
+	if name == "" {
+		return nil, nil
+	}
+	pc, ok := fs.config.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown fetch profile: %s", name)
+	}
+	fs.profilesMu.Lock()
+	p, ok := fs.profiles[name]
+	if !ok {
+		p = &fetchProfile{name: name, cred: pc.Credential}
+		fs.profiles[name] = p
+	}
+	fs.profilesMu.Unlock()
+
+	// Importing Chrome cookies can take a while, only callers of this
+	// profile wait for it.
+	p.load.Do(func() { p.err = fs.loadProfile(ctx, p, pc) })
+	if p.err != nil {
+		// Forget the failed attempt so the next call tries again.
+		fs.profilesMu.Lock()
+		if fs.profiles[name] == p {
+			delete(fs.profiles, name)
+		}
+		fs.profilesMu.Unlock()
+		return nil, p.err
+	}
+	return p, nil
+}
+
+// loadProfile creates the client and cookie jar of p
+func (fs *FetchService) loadProfile(ctx context.Context, p *fetchProfile, pc config.Profile) error {
+	jar, existed, err := newPersistentJar(fs.store, "cookies_"+url.PathEscape(p.name)+".json")
+	if err != nil {
+		return err
+	}
+	if !existed && pc.ChromeUserDataDir != "" {
+		cookies, err := chromeCookies(ctx, chromeOptions(fs.config), pc.ChromeUserDataDir)
+		if err != nil {
+			slog.Warn("Failed to import Chrome cookies", "profile", p.name, "error", err)
+		} else {
+			jar.importCookies(cookies)
+		}
+	}
+
+	p.client = &http.Client{
+		Transport: fs.client.Transport,
+		Jar:       jar,
+		Timeout:   fs.client.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !pc.Allows(req.URL) {
+				return fmt.Errorf("fetch profile %s may not be used for %s", p.name, req.URL.Redacted())
+			}
+			dropCredential(req, via, pc.Credential)
+			return fs.client.CheckRedirect(req, via)
+		},
+	}
+	return nil
+}
+
+// storedCookie is a cookie as persisted in a profile's jar file
+type storedCookie struct {
+	URL      string    `json:"url"`
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Expires  time.Time `json:"expires,omitempty"`
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"http_only,omitempty"`
+}
+
+func (c *storedCookie) key() string {
+	host := c.Domain
+	if host == "" {
+		if u, err := url.Parse(c.URL); err == nil {
+			host = u.Hostname()
+		}
+	}
+	return host + ";" + c.Path + ";" + c.Name
+}
+
+func (c *storedCookie) expired(now time.Time) bool {
+	return !c.Expires.IsZero() && !c.Expires.After(now)
+}
+
+// persistentJar is a cookie jar that writes every cookie it accepts to the
+// store, so sessions survive restarts. Session cookies are kept as well.
+type persistentJar struct {
+	mu      sync.Mutex
+	jar     *cookiejar.Jar
+	store   *store
+	file    string
+	cookies map[string]*storedCookie
+}
+
+func newPersistentJar(s *store, file string) (*persistentJar, bool, error) {
+	jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
+	if err != nil {
+		return nil, false, err
+	}
+	pj := &persistentJar{
+		jar:     jar,
+		store:   s,
+		file:    file,
+		cookies: make(map[string]*storedCookie),
+	}
+
+	var stored []*storedCookie
+	if err := s.load(file, &stored); err != nil {
+		return nil, false, err
+	}
+	now := time.Now()
+	for _, c := range stored {
+		if c.expired(now) {
+			continue
+		}
+		u, err := url.Parse(c.URL)
+		if err != nil {
+			continue
+		}
+		jar.SetCookies(u, []*http.Cookie{c.httpCookie()})
+		pj.cookies[c.key()] = c
+	}
+	return pj, stored != nil, nil
+}
+
+func (c *storedCookie) httpCookie() *http.Cookie {
+	return &http.Cookie{
+		Name:     c.Name,
+		Value:    c.Value,
+		Domain:   c.Domain,
+		Path:     c.Path,
+		Expires:  c.Expires,
+		Secure:   c.Secure,
+		HttpOnly: c.HttpOnly,
+	}
+}
+
+func (pj *persistentJar) Cookies(u *url.URL) []*http.Cookie {
+	return pj.jar.Cookies(u)
+}
+
+func (pj *persistentJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	pj.jar.SetCookies(u, cookies)
+
+	pj.mu.Lock()
+	defer pj.mu.Unlock()
+	now := time.Now()
+	origin := (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path}).String()
+	for _, c := range cookies {
+		sc := &storedCookie{
+			URL:      origin,
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   strings.TrimPrefix(c.Domain, "."),
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		}
+		switch {
+		case c.MaxAge < 0:
+			sc.Expires = now
+		case c.MaxAge > 0:
+			sc.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+		}
+		if sc.expired(now) {
+			delete(pj.cookies, sc.key())
+			continue
+		}
+		pj.cookies[sc.key()] = sc
+	}
+	if err := pj.save(); err != nil {
+		slog.Warn("Failed to save cookies", "file", pj.file, "error", err)
+	}
+}
+
+// save must be called with mu held
+func (pj *persistentJar) save() error {
+	stored := make([]*storedCookie, 0, len(pj.cookies))
+	for _, c := range pj.cookies {
+		stored = append(stored, c)
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].key() < stored[j].key() })
+	return pj.store.save(pj.file, stored)
+}
+
+// importCookies adds cookies read from Chrome to the jar
+func (pj *persistentJar) importCookies(cookies []*network.Cookie) {
+	for _, c := range cookies {
+		scheme := "http"
+		if c.Secure {
+			scheme = "https"
+		}
+		u := &url.URL{Scheme: scheme, Host: strings.TrimPrefix(c.Domain, "."), Path: c.Path}
+		hc := &http.Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Secure:   c.Secure,
+			HttpOnly: c.HTTPOnly,
+		}
+		// A leading dot marks a domain cookie, anything else is host-only.
+		if strings.HasPrefix(c.Domain, ".") {
+			hc.Domain = c.Domain
+		}
+		if !c.Session && c.Expires > 0 {
+			hc.Expires = time.Unix(int64(c.Expires), 0)
+		}
+		pj.SetCookies(u, []*http.Cookie{hc})
+	}
+}
+
+// chromeCookies starts a headless Chrome with opts on userDataDir and reads
+// all of its cookies, which Chrome decrypts itself. The profile must not be in
+// use by another Chrome instance, one of the browser service holds its lock.
+func chromeCookies(ctx context.Context, opts []chromedp.ExecAllocatorOption, userDataDir string) ([]*network.Cookie, error) {
+	unlock, err := browser.LockProfileDir(userDataDir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read cookies of %s: %v", userDataDir, err)
+	}
+	defer unlock()
+	opts = append(opts[:len(opts):len(opts)], chromedp.UserDataDir(userDataDir))
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), opts...)
+	defer cancelAlloc()
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	defer cancelBrowser()
+	browserCtx, cancelTimeout := context.WithTimeout(browserCtx, chromeImportTimeout)
+	defer cancelTimeout()
+	stop := context.AfterFunc(ctx, cancelBrowser)
+	defer stop()
+
+	var cookies []*network.Cookie
+	err = chromedp.Run(browserCtx, chromedp.ActionFunc(func(ctx context.Context) error {
+		var err error
+		cookies, err = storage.GetCookies().Do(ctx)
+		return err
+	}))
+	return cookies, err
+}
@@ -0,0 +1,163 @@
+package fetch
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	browser "github.com/dyike/MonoMCPHub/internal/browser/service"
+	"github.com/dyike/MonoMCPHub/internal/fetch/config"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestFetchProfile(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "no token", http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc", Path: "/", MaxAge: 3600})
+			w.Write([]byte("logged in"))
+		case "/private":
+			if c, err := r.Cookie("session"); err != nil || c.Value != "abc" {
+				http.Error(w, "no session", http.StatusForbidden)
+				return
+			}
+			w.Write([]byte("private data"))
+		}
+	}))
+	defer ts.Close()
+
+	cfg := config.NewFetchConfig()
+	cfg.DataPath = t.TempDir()
+	cfg.Profiles["work"] = config.Profile{Credential: config.Credential{Hosts: []string{"127.0.0.1"}, BearerToken: "s3cret"}}
+
+	fetch := func(fs *FetchService, path, profile string) *mcp.CallToolResult {
+		t.Helper()
+		result, err := fs.handleFetchURL(context.Background(), newFetchRequest(map[string]interface{}{
+			"url":     ts.URL + path,
+			"profile": profile,
+			"render":  renderNever,
+		}))
+		if err != nil {
+			t.Fatalf("fetch_url failed: %v", err)
+		}
+		for _, c := range result.Content {
+			if tc, ok := c.(mcp.TextContent); ok && strings.Contains(tc.Text, "s3cret") {
+				t.Errorf("secret leaked into output: %s", tc.Text)
+			}
+		}
+		return result
+	}
+
+	fs := newTestService(cfg)
+	if result := fetch(fs, "/login", ""); !result.IsError {
+		t.Errorf("expected fetch without profile to be rejected")
+	}
+	if result := fetch(fs, "/login", "work"); result.IsError {
+		t.Fatalf("login failed: %v", result.Content)
+	}
+
+	// The session cookie is persisted and used by a new service.
+	fs = newTestService(cfg)
+	result := fetch(fs, "/private", "work")
+	if result.IsError || !strings.Contains(result.Content[0].(mcp.TextContent).Text, "private data") {
+		t.Errorf("expected persisted session to be used, got %v", result.Content)
+	}
+	if result := fetch(fs, "/private", "missing"); !result.IsError {
+		t.Errorf("expected unknown profile to be rejected")
+	}
+}
+
+func TestCredentialDroppedOnCrossHostRedirect(t *testing.T) {
+	var leaked []string
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, h := range []string{"Authorization", "X-Api-Key"} {
+			if r.Header.Get(h) != "" {
+				leaked = append(leaked, h)
+			}
+		}
+		w.Write([]byte("other host"))
+	}))
+	defer other.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "k3y" {
+			http.Error(w, "no key", http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, other.URL+"/landing", http.StatusFound)
+	}))
+	defer ts.Close()
+
+	cred := config.Credential{Hosts: []string{"127.0.0.1"}, Headers: map[string]string{"X-Api-Key": "k3y"}, BearerToken: "s3cret"}
+	cfg := config.NewFetchConfig()
+	cfg.DataPath = t.TempDir()
+	cfg.Profiles["work"] = config.Profile{Credential: cred}
+	cfg.Credentials["api"] = cred
+	fs := newTestService(cfg)
+
+	result, err := fs.handleFetchURL(context.Background(), newFetchRequest(map[string]interface{}{
+		"url":     ts.URL,
+		"profile": "work",
+		"render":  renderNever,
+	}))
+	if err != nil || result.IsError {
+		t.Fatalf("fetch_url failed: %v %v", err, result)
+	}
+	var request mcp.CallToolRequest
+	request.Params.Arguments = map[string]interface{}{"url": ts.URL, "credential": "api"}
+	if result, err = fs.handleHTTPRequest(context.Background(), request); err != nil || result.IsError {
+		t.Fatalf("http_request failed: %v %v", err, result)
+	}
+	if len(leaked) > 0 {
+		t.Errorf("credential headers were sent to another host: %v", leaked)
+	}
+}
+
+func TestFetchProfileHosts(t *testing.T) {
+	var leaked bool
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		leaked = leaked || r.Header.Get("Authorization") != ""
+		w.Write([]byte("other host"))
+	}))
+	defer other.Close()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, other.URL, http.StatusFound)
+	}))
+	defer ts.Close()
+
+	cfg := config.NewFetchConfig()
+	cfg.DataPath = t.TempDir()
+	cfg.Profiles["work"] = config.Profile{Credential: config.Credential{Hosts: []string{ts.URL + "/"}, BearerToken: "s3cret"}}
+	fs := newTestService(cfg)
+
+	for _, target := range []string{other.URL, ts.URL} {
+		result, err := fs.handleFetchURL(context.Background(), newFetchRequest(map[string]interface{}{
+			"url":     target,
+			"profile": "work",
+			"render":  renderNever,
+		}))
+		if err == nil && !result.IsError {
+			t.Errorf("expected fetching %s with the profile to fail, it leaves the profile's hosts", target)
+		}
+	}
+	if leaked {
+		t.Errorf("profile credentials reached a host outside the profile")
+	}
+}
+
+func TestChromeCookiesRespectsBrowserLock(t *testing.T) {
+	dir := t.TempDir()
+	unlock, err := browser.LockProfileDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unlock()
+	if _, err := chromeCookies(context.Background(), chromeOptions(config.NewFetchConfig()), dir); err == nil {
+		t.Errorf("expected a locked profile to be refused")
+	}
+}
@@ -0,0 +1,184 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/chromedp/cdproto/cdp"
+	cdpfetch "github.com/chromedp/cdproto/fetch"
+	"github.com/chromedp/cdproto/network"
+	"github.com/chromedp/chromedp"
+	bconfig "github.com/dyike/MonoMCPHub/internal/browser/config"
+	browser "github.com/dyike/MonoMCPHub/internal/browser/service"
+	"github.com/dyike/MonoMCPHub/internal/fetch/config"
+	"github.com/dyike/MonoMCPHub/pkg/httpclient"
+)
+
+const (
+	renderAuto   = "auto"
+	renderAlways = "always"
+	renderNever  = "never"
+
+	// minTextLength is the amount of visible text below which an HTML page is
+	// considered an empty client-side rendered shell in render=auto mode
+	minTextLength = 200
+)
+
+// renderer renders pages in a headless Chrome that is started on first use
+// and shared by all fetches; every render runs in its own tab.
+type renderer struct {
+	mu         sync.Mutex
+	opts       []chromedp.ExecAllocatorOption
+	browserCtx context.Context
+	cancel     context.CancelFunc
+	// vet checks every request the browser makes, nil lets all through
+	vet func(ctx context.Context, u *url.URL) error
+}
+
+// newRenderer returns a renderer whose requests go through g, unless g
+// allows private networks anyway
+func newRenderer(cfg *config.FetchConfig, g *guard) *renderer {
+	r := &renderer{
+		opts: chromeOptions(cfg),
+	}
+	if !g.allowPrivate {
+		r.vet = g.vetURL
+	}
+	return r
+}
+
+// chromeOptions launches Chrome with the user agent, proxy and CA settings
+// of the fetch config, so rendered pages leave the machine like fetched ones
+func chromeOptions(cfg *config.FetchConfig) []chromedp.ExecAllocatorOption {
+	bconf := bconfig.NewBrowserConfig()
+	bconf.UserAgent = cfg.UserAgent
+	return browser.ChromeOptions(bconf, httpclient.Resolve(cfg.HTTP))
+}
+
+func (r *renderer) browser(ctx context.Context) (context.Context, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.browserCtx != nil {
+		return r.browserCtx, nil
+	}
+
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, r.opts...)
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	if err := chromedp.Run(browserCtx); err != nil {
+		cancelBrowser()
+		cancelAlloc()
+		return nil, fmt.Errorf("failed to start browser: %v", err)
+	}
+	if r.vet != nil {
+		if err := r.intercept(browserCtx); err != nil {
+			cancelBrowser()
+			cancelAlloc()
+			return nil, fmt.Errorf("failed to guard browser requests: %v", err)
+		}
+	}
+	r.browserCtx = browserCtx
+	r.cancel = func() {
+		cancelBrowser()
+		cancelAlloc()
+	}
+	return r.browserCtx, nil
+}
+
+// intercept pauses every request of the browser, from any tab, frame or
+// worker and across redirects, and only lets those through that pass vet.
+// WebSockets are not covered by the Fetch domain.
+func (r *renderer) intercept(browserCtx context.Context) error {
+	c := chromedp.FromContext(browserCtx)
+	execCtx := cdp.WithExecutor(browserCtx, c.Browser)
+	chromedp.ListenBrowser(browserCtx, func(ev any) {
+		e, ok := ev.(*cdpfetch.EventRequestPaused)
+		if !ok {
+			return
+		}
+		// Listeners must not block, the lookup and the reply may take a while.
+		go func() {
+			u, err := url.Parse(e.Request.URL)
+			if err == nil {
+				err = r.vet(execCtx, u)
+			}
+			if err != nil {
+				slog.Debug("Blocked browser request", "url", e.Request.URL, "error", err)
+				err = cdpfetch.FailRequest(e.RequestID, network.ErrorReasonBlockedByClient).Do(execCtx)
+			} else {
+				err = cdpfetch.ContinueRequest(e.RequestID).Do(execCtx)
+			}
+			if err != nil && execCtx.Err() == nil {
+				slog.Debug("Failed to resume browser request", "url", e.Request.URL, "error", err)
+			}
+		}()
+	})
+	return cdpfetch.Enable().
+		WithPatterns([]*cdpfetch.RequestPattern{{URLPattern: "*"}}).
+		Do(execCtx)
+}
+
+// render loads url in a new tab, waits for waitSelector to be visible or, when
+// it is empty, for the network to go idle, and returns the rendered HTML and
+// the URL the page ended up at.
+func (r *renderer) render(ctx context.Context, serviceCtx context.Context, url, waitSelector string, timeout time.Duration) (string, string, error) {
+	browserCtx, err := r.browser(serviceCtx)
+	if err != nil {
+		return "", "", err
+	}
+
+	tabCtx, cancelTab := chromedp.NewContext(browserCtx)
+	defer cancelTab()
+	tabCtx, cancelTimeout := context.WithTimeout(tabCtx, timeout)
+	defer cancelTimeout()
+	stop := context.AfterFunc(ctx, cancelTab)
+	defer stop()
+
+	var actions []chromedp.Action
+	if waitSelector != "" {
+		actions = append(actions,
+			chromedp.Navigate(url),
+			chromedp.WaitVisible(waitSelector, chromedp.ByQuery),
+		)
+	} else {
+		actions = append(actions, browser.NavigateNetworkIdle(url))
+	}
+	var html, location string
+	actions = append(actions,
+		chromedp.OuterHTML("html", &html, chromedp.ByQuery),
+		chromedp.Location(&location),
+	)
+
+	if err := chromedp.Run(tabCtx, actions...); err != nil {
+		return "", "", fmt.Errorf("failed to render %s: %v", url, err)
+	}
+	return html, location, nil
+}
+
+func (r *renderer) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.cancel != nil {
+		r.cancel()
+		r.browserCtx = nil
+		r.cancel = nil
+	}
+}
+
+// isNearlyEmpty reports whether an HTML page has almost no visible text, as is
+// the case for shells that are filled in by JavaScript.
+func isNearlyEmpty(html []byte) bool {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(html))
+	if err != nil {
+		return false
+	}
+	body := doc.Find("body")
+	body.Find("script,style,noscript,template").Remove()
+	return len(strings.Join(strings.Fields(body.Text()), " ")) < minTextLength
+}
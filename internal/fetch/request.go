@@ -0,0 +1,277 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dyike/MonoMCPHub/internal/fetch/config"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// HTTPResponse is the structured result of the http_request tool
+type HTTPResponse struct {
+	Status       string            `json:"status"`
+	StatusCode   int               `json:"status_code"`
+	URL          string            `json:"url"`
+	Headers      map[string]string `json:"headers"`
+	Body         string            `json:"body"`
+	BodyEncoding string            `json:"body_encoding,omitempty"`
+	Truncated    bool              `json:"truncated,omitempty"`
+	DurationMs   int64             `json:"duration_ms"`
+}
+
+func newHTTPRequestTool() mcp.Tool {
+	return mcp.NewTool("http_request",
+		mcp.WithDescription("Send an arbitrary HTTP request and return the status, headers and body"),
+		mcp.WithString("url",
+			mcp.Required(),
+			mcp.Description("The URL to request"),
+		),
+		mcp.WithString("method",
+			mcp.Description("The HTTP method"),
+			mcp.DefaultString("GET"),
+			mcp.Enum("GET", "HEAD", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"),
+		),
+		mcp.WithObject("headers",
+			mcp.Description("Request headers as a map of name to value"),
+		),
+		mcp.WithObject("query",
+			mcp.Description("Query parameters added to the URL as a map of name to value"),
+		),
+		mcp.WithObject("json",
+			mcp.Description("JSON request body, sent with Content-Type application/json"),
+		),
+		mcp.WithObject("form",
+			mcp.Description("Form request body as a map of name to value, sent URL-encoded"),
+		),
+		mcp.WithString("body",
+			mcp.Description("Raw request body"),
+		),
+		mcp.WithString("content_type",
+			mcp.Description("Content-Type of the raw body"),
+		),
+		mcp.WithNumber("timeout",
+			mcp.Description("Request timeout in seconds, defaults to the configured timeout"),
+		),
+		mcp.WithBoolean("follow_redirects",
+			mcp.Description("Follow redirects"),
+			mcp.DefaultBool(true),
+		),
+		mcp.WithNumber("max_redirects",
+			mcp.Description("Maximum number of redirects to follow, capped by the configured limit"),
+		),
+		mcp.WithNumber("max_body_size",
+			mcp.Description("Maximum number of response body bytes to return, capped by the configured limit"),
+		),
+		mcp.WithString("credential",
+			mcp.Description("Name of a configured credential profile to authenticate the request with, only usable for the hosts configured for it"),
+		),
+	)
+}
+
+func (fs *FetchService) handleHTTPRequest(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+
+	rawURL, ok := args["url"].(string)
+	if !ok || rawURL == "" {
+		return mcp.NewToolResultError("url must be a string"), nil
+	}
+	method, _ := args["method"].(string)
+	if method == "" {
+		method = http.MethodGet
+	}
+	method = strings.ToUpper(method)
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Invalid url: %v", err)), nil
+	}
+	if err := fs.guard.checkTarget(ctx, u); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	if query, ok := args["query"].(map[string]interface{}); ok {
+		q := u.Query()
+		for k, v := range query {
+			q.Set(k, stringValue(v))
+		}
+		u.RawQuery = q.Encode()
+	}
+
+	body, contentType, err := requestBody(args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to create request: %v", err)), nil
+	}
+	req.Header.Set("User-Agent", fs.config.UserAgent)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if headers, ok := args["headers"].(map[string]interface{}); ok {
+		for k, v := range headers {
+			req.Header.Set(k, stringValue(v))
+		}
+	}
+	var cred *config.Credential
+	credName, _ := args["credential"].(string)
+	if credName != "" {
+		c, ok := fs.config.Credentials[credName]
+		if !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("Unknown credential profile: %s", credName)), nil
+		}
+		if !c.Allows(req.URL) {
+			return mcp.NewToolResultError(fmt.Sprintf("Credential %s may not be sent to %s", credName, req.URL.Redacted())), nil
+		}
+		cred = &c
+		applyCredential(req, c)
+	}
+
+	timeout := time.Duration(fs.config.Timeout) * time.Second
+	if t, ok := args["timeout"].(float64); ok && t > 0 {
+		timeout = time.Duration(t * float64(time.Second))
+	}
+	followRedirects, ok := args["follow_redirects"].(bool)
+	if !ok {
+		followRedirects = true
+	}
+	maxRedirects := fs.config.MaxRedirects
+	if n, ok := args["max_redirects"].(float64); ok && n >= 0 {
+		maxRedirects = int(n)
+	}
+	maxBody := fs.config.MaxBodySize
+	if n, ok := args["max_body_size"].(float64); ok && n > 0 && int64(n) < maxBody {
+		maxBody = int64(n)
+	}
+
+	client := &http.Client{
+		Transport: fs.client.Transport,
+		Jar:       fs.client.Jar,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !followRedirects {
+				return http.ErrUseLastResponse
+			}
+			if cred != nil {
+				// Every hop must be allowed too, even though dropCredential
+				// strips the secrets when the host changes.
+				if !cred.Allows(req.URL) {
+					return fmt.Errorf("credential %s may not be sent to %s", credName, req.URL.Redacted())
+				}
+				dropCredential(req, via, *cred)
+			}
+			return fs.guard.checkRedirect(req, via, maxRedirects)
+		},
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Request failed: %v", redactURLError(err))), nil
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBody+1))
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Failed to read response body: %v", err)), nil
+	}
+
+	result := HTTPResponse{
+		Status:     resp.Status,
+		StatusCode: resp.StatusCode,
+		URL:        resp.Request.URL.String(),
+		Headers:    make(map[string]string, len(resp.Header)),
+		DurationMs: time.Since(start).Milliseconds(),
+	}
+	for k, v := range resp.Header {
+		result.Headers[k] = strings.Join(v, ", ")
+	}
+	if int64(len(data)) > maxBody {
+		data = truncateUTF8(data, int(maxBody))
+		result.Truncated = true
+	}
+	if utf8.Valid(data) {
+		result.Body = string(data)
+	} else {
+		result.Body = base64.StdEncoding.EncodeToString(data)
+		result.BodyEncoding = "base64"
+	}
+
+	payload, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(string(payload)), nil
+}
+
+// requestBody builds the request body from the json, form or body arguments,
+// of which at most one may be set.
+func requestBody(args map[string]interface{}) (io.Reader, string, error) {
+	jsonBody, hasJSON := args["json"]
+	form, hasForm := args["form"].(map[string]interface{})
+	raw, hasRaw := args["body"].(string)
+
+	set := 0
+	for _, has := range []bool{hasJSON && jsonBody != nil, hasForm, hasRaw} {
+		if has {
+			set++
+		}
+	}
+	if set > 1 {
+		return nil, "", errors.New("only one of json, form and body may be set")
+	}
+
+	switch {
+	case hasJSON && jsonBody != nil:
+		data, err := json.Marshal(jsonBody)
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid json body: %v", err)
+		}
+		return bytes.NewReader(data), "application/json", nil
+	case hasForm:
+		values := url.Values{}
+		for k, v := range form {
+			values.Set(k, stringValue(v))
+		}
+		return strings.NewReader(values.Encode()), "application/x-www-form-urlencoded", nil
+	case hasRaw:
+		contentType, _ := args["content_type"].(string)
+		return strings.NewReader(raw), contentType, nil
+	}
+	return nil, "", nil
+}
+
+// redactURLError drops the request URL from transport errors, which may carry
+// credentials in userinfo or query parameters.
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+func stringValue(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case float64:
+		return fmt.Sprintf("%v", val)
+	case nil:
+		return ""
+	}
+	data, _ := json.Marshal(v)
+	return string(data)
+}
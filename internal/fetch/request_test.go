@@ -0,0 +1,126 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dyike/MonoMCPHub/internal/fetch/config"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestHTTPRequestCredential(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"method":"` + r.Method + `","q":"` + r.URL.Query().Get("q") + `","body":` + string(body) + `}`))
+	}))
+	defer ts.Close()
+
+	cfg := config.NewFetchConfig()
+	cfg.Credentials["api"] = config.Credential{Hosts: []string{"127.0.0.1"}, BearerToken: "s3cret"}
+	fs := newTestService(cfg)
+
+	var request mcp.CallToolRequest
+	request.Params.Arguments = map[string]interface{}{
+		"url":        ts.URL,
+		"method":     "post",
+		"query":      map[string]interface{}{"q": "go"},
+		"json":       map[string]interface{}{"name": "hub"},
+		"credential": "api",
+	}
+	result, err := fs.handleHTTPRequest(context.Background(), request)
+	if err != nil {
+		t.Fatalf("handleHTTPRequest failed: %v", err)
+	}
+	text, ok := mcp.AsTextContent(result.Content[0])
+	if !ok {
+		t.Fatalf("expected text content, got %v", result.Content[0])
+	}
+	if strings.Contains(text.Text, "s3cret") {
+		t.Errorf("credential leaked into tool output: %s", text.Text)
+	}
+
+	var resp HTTPResponse
+	if err := json.Unmarshal([]byte(text.Text), &resp); err != nil {
+		t.Fatalf("failed to decode result: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", resp.StatusCode, resp.Body)
+	}
+	if resp.Body != `{"method":"POST","q":"go","body":{"name":"hub"}}` {
+		t.Errorf("unexpected body: %s", resp.Body)
+	}
+}
+
+func TestHTTPRequestCredentialHosts(t *testing.T) {
+	var leaked bool
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/redirect" {
+			http.Redirect(w, r, "/other", http.StatusFound)
+			return
+		}
+		leaked = leaked || r.URL.Path != "/api/ok" && r.Header.Get("Authorization") != ""
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	cfg := config.NewFetchConfig()
+	cfg.Credentials["api"] = config.Credential{Hosts: []string{ts.URL + "/api/"}, BearerToken: "s3cret"}
+	fs := newTestService(cfg)
+
+	call := func(path string) *mcp.CallToolResult {
+		t.Helper()
+		var request mcp.CallToolRequest
+		request.Params.Arguments = map[string]interface{}{"url": ts.URL + path, "credential": "api"}
+		result, err := fs.handleHTTPRequest(context.Background(), request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+	if result := call("/api/ok"); result.IsError {
+		t.Errorf("expected request within the allowed prefix to succeed: %v", result.Content)
+	}
+	if result := call("/apix"); !result.IsError {
+		t.Errorf("expected request outside the allowed prefix to be rejected")
+	}
+	if result := call("/api/redirect"); !result.IsError {
+		t.Errorf("expected redirect outside the allowed prefix to be rejected")
+	}
+	if leaked {
+		t.Errorf("credential was sent outside its hosts")
+	}
+}
+
+func TestCredentialAllows(t *testing.T) {
+	cred := config.Credential{Hosts: []string{"api.example.com", "*.example.org", "https://example.net/v1/"}}
+	cases := map[string]bool{
+		"https://api.example.com/x":        true,
+		"http://API.example.com:8080/":     true,
+		"https://example.com/":             false,
+		"https://api.example.com.evil.io/": false,
+		"https://a.b.example.org/":         true,
+		"https://example.org/":             false,
+		"https://example.net/v1/items":     true,
+		"https://example.net/v1":           false,
+		"https://example.net/v10/":         false,
+		"http://example.net/v1/items":      false,
+		"https://example.net.evil.io/v1/":  false,
+	}
+	for raw, want := range cases {
+		u, _ := url.Parse(raw)
+		if got := cred.Allows(u); got != want {
+			t.Errorf("Allows(%s) = %v, want %v", raw, got, want)
+		}
+	}
+}
@@ -0,0 +1,274 @@
+package fetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	schemeRaw      = "fetch://"
+	schemeMarkdown = "fetch-md://"
+
+	// resourceTTL is how long a cached page is served before it is fetched again
+	resourceTTL = 5 * time.Minute
+
+	// maxCachedPages bounds the page cache, the oldest pages are dropped first
+	maxCachedPages = 100
+
+	// maxSubscribers bounds the sessions notified about one resource
+	maxSubscribers = 16
+)
+
+// cachedPage is a fetched page backing the fetch:// and fetch-md:// resources
+type cachedPage struct {
+	url         string
+	contentType string
+	body        []byte
+	sum         [sha256.Size]byte
+	fetchedAt   time.Time
+}
+
+// resourceCache keeps recently fetched pages and the sessions that read them.
+// mcp-go does not route resources/subscribe, so reading a resource subscribes
+// the session to notifications/resources/updated for it. Subscriptions to a
+// page end when it is dropped from the cache.
+type resourceCache struct {
+	mu          sync.Mutex
+	ttl         time.Duration
+	pages       map[string]*cachedPage
+	order       []string
+	subscribers map[string]map[string]server.ClientSession
+}
+
+func newResourceCache() *resourceCache {
+	return &resourceCache{
+		ttl:         resourceTTL,
+		pages:       make(map[string]*cachedPage),
+		subscribers: make(map[string]map[string]server.ClientSession),
+	}
+}
+
+func (rc *resourceCache) get(target string) (*cachedPage, bool) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	page, ok := rc.pages[target]
+	if !ok || time.Since(page.fetchedAt) > rc.ttl {
+		return nil, false
+	}
+	return page, true
+}
+
+// put stores page, dropping the oldest pages and their subscribers to make
+// room for it, and reports whether it replaced a cached copy with different
+// content.
+func (rc *resourceCache) put(page *cachedPage) bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	old, ok := rc.pages[page.url]
+	if !ok {
+		rc.order = append(rc.order, page.url)
+		for len(rc.order) > maxCachedPages {
+			target := rc.order[0]
+			rc.order = rc.order[1:]
+			delete(rc.pages, target)
+			delete(rc.subscribers, schemeRaw+target)
+			delete(rc.subscribers, schemeMarkdown+target)
+		}
+	}
+	rc.pages[page.url] = page
+	return ok && old.sum != page.sum
+}
+
+// subscribe adds session to the subscribers of uri, unless uri has too many
+// subscribers already
+func (rc *resourceCache) subscribe(uri string, session server.ClientSession) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	sessions, ok := rc.subscribers[uri]
+	if !ok {
+		sessions = make(map[string]server.ClientSession)
+		rc.subscribers[uri] = sessions
+	}
+	if _, ok := sessions[session.SessionID()]; !ok && len(sessions) >= maxSubscribers {
+		return
+	}
+	sessions[session.SessionID()] = session
+}
+
+// unsubscribe drops a session that could not be notified, mcp-go does not
+// tell us when a session ends
+func (rc *resourceCache) unsubscribe(uri string, session server.ClientSession) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	sessions := rc.subscribers[uri]
+	delete(sessions, session.SessionID())
+	if len(sessions) == 0 {
+		delete(rc.subscribers, uri)
+	}
+}
+
+// forget drops all subscribers of uri
+func (rc *resourceCache) forget(uri string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	delete(rc.subscribers, uri)
+}
+
+func (rc *resourceCache) sessions(uri string) []server.ClientSession {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	sessions := make([]server.ClientSession, 0, len(rc.subscribers[uri]))
+	for _, s := range rc.subscribers[uri] {
+		sessions = append(sessions, s)
+	}
+	return sessions
+}
+
+func (fs *FetchService) registerResources() {
+	fs.AddResourceTemplateHandler(mcp.NewResourceTemplate(schemeRaw+"{+url}", "Fetched page",
+		mcp.WithTemplateDescription("The raw content of a web page, e.g. fetch://https://example.com/. Reading it subscribes to updates of the page"),
+	), fs.handleReadResource)
+	fs.AddResourceTemplateHandler(mcp.NewResourceTemplate(schemeMarkdown+"{+url}", "Fetched page as Markdown",
+		mcp.WithTemplateDescription("A web page converted to Markdown, e.g. fetch-md://https://example.com/. Reading it subscribes to updates of the page"),
+		mcp.WithTemplateMIMEType("text/markdown"),
+	), fs.handleReadResource)
+}
+
+func (fs *FetchService) handleReadResource(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	uri := request.Params.URI
+	target, markdown, err := parseResourceURI(uri)
+	if err != nil {
+		return nil, err
+	}
+	page, ok := fs.resources.get(target)
+	if !ok {
+		resp, body, err := fs.get(ctx, target)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode >= http.StatusBadRequest {
+			return nil, fmt.Errorf("HTTP error: %s", resp.Status)
+		}
+		page = fs.cachePage(ctx, target, resp, body)
+	}
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		fs.resources.subscribe(uri, session)
+	}
+
+	if markdown {
+		return page.markdown(uri)
+	}
+	return page.raw(uri)
+}
+
+// cachePage stores a successful fetch so it can be read as a resource and
+// notifies subscribers when the content changed since the last fetch. Pages
+// are only served through the resource templates, they are not listed one by
+// one.
+func (fs *FetchService) cachePage(ctx context.Context, target string, resp *http.Response, body []byte) *cachedPage {
+	page := &cachedPage{
+		url:         target,
+		contentType: resp.Header.Get("Content-Type"),
+		body:        body,
+		sum:         sha256.Sum256(body),
+		fetchedAt:   time.Now(),
+	}
+
+	changed := fs.resources.put(page)
+	srv := server.ServerFromContext(ctx)
+	if srv == nil || !changed {
+		return page
+	}
+	for _, uri := range []string{schemeRaw + target, schemeMarkdown + target} {
+		for _, session := range fs.resources.sessions(uri) {
+			err := srv.SendNotificationToClient(srv.WithContext(fs.Ctx(), session), "notifications/resources/updated", map[string]interface{}{
+				"uri": uri,
+			})
+			if err != nil {
+				fs.resources.unsubscribe(uri, session)
+			}
+		}
+	}
+	return page
+}
+
+// parseResourceURI splits a fetch:// or fetch-md:// URI into the page URL and
+// whether Markdown was asked for. The page URL may be percent-encoded.
+func parseResourceURI(uri string) (string, bool, error) {
+	var target string
+	markdown := false
+	switch {
+	case strings.HasPrefix(uri, schemeMarkdown):
+		target, markdown = strings.TrimPrefix(uri, schemeMarkdown), true
+	case strings.HasPrefix(uri, schemeRaw):
+		target = strings.TrimPrefix(uri, schemeRaw)
+	default:
+		return "", false, fmt.Errorf("unsupported resource URI: %s", uri)
+	}
+	if !strings.Contains(target, "://") {
+		unescaped, err := url.PathUnescape(target)
+		if err != nil {
+			return "", false, fmt.Errorf("invalid resource URI: %s", uri)
+		}
+		target = unescaped
+	}
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", false, fmt.Errorf("resource URI must wrap an http or https URL: %s", uri)
+	}
+	return target, markdown, nil
+}
+
+func (p *cachedPage) raw(uri string) ([]mcp.ResourceContents, error) {
+	kind, mediaType := detectContentKind(p.contentType, p.body)
+	switch kind {
+	case kindHTML, kindText:
+		body, err := decodeBody(p.body, p.contentType)
+		if err != nil {
+			return nil, err
+		}
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{URI: uri, MIMEType: mediaType, Text: string(body)},
+		}, nil
+	case kindJSON:
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{URI: uri, MIMEType: mediaType, Text: string(p.body)},
+		}, nil
+	}
+	return []mcp.ResourceContents{
+		mcp.BlobResourceContents{URI: uri, MIMEType: mediaType, Blob: base64.StdEncoding.EncodeToString(p.body)},
+	}, nil
+}
+
+// markdown runs the page through the fetch_url pipeline, images stay binary
+func (p *cachedPage) markdown(uri string) ([]mcp.ResourceContents, error) {
+	resp := &http.Response{Header: http.Header{"Content-Type": {p.contentType}}}
+	result, err := renderResponse(resp, p.body, fetchOptions{})
+	if err != nil {
+		return nil, err
+	}
+	contents := make([]mcp.ResourceContents, 0, len(result.Content))
+	for _, c := range result.Content {
+		switch c := c.(type) {
+		case mcp.TextContent:
+			if result.IsError {
+				return nil, fmt.Errorf("%s", c.Text)
+			}
+			contents = append(contents, mcp.TextResourceContents{URI: uri, MIMEType: "text/markdown", Text: c.Text})
+		case mcp.ImageContent:
+			contents = append(contents, mcp.BlobResourceContents{URI: uri, MIMEType: c.MIMEType, Blob: c.Data})
+		}
+	}
+	return contents, nil
+}
@@ -0,0 +1,137 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+type testSession struct {
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (s *testSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+func (s *testSession) SessionID() string {
+	return "test"
+}
+
+// blockedSession never takes a notification
+type blockedSession struct{}
+
+func (s *blockedSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return nil
+}
+
+func (s *blockedSession) SessionID() string {
+	return "blocked"
+}
+
+func TestParseResourceURI(t *testing.T) {
+	cases := []struct {
+		uri      string
+		target   string
+		markdown bool
+	}{
+		{"fetch://https://example.com/a?b=c", "https://example.com/a?b=c", false},
+		{"fetch-md://https://example.com/", "https://example.com/", true},
+		{"fetch-md://https%3A%2F%2Fexample.com%2Fx", "https://example.com/x", true},
+	}
+	for _, c := range cases {
+		target, markdown, err := parseResourceURI(c.uri)
+		if err != nil || target != c.target || markdown != c.markdown {
+			t.Errorf("parseResourceURI(%s) = %s, %v, %v", c.uri, target, markdown, err)
+		}
+	}
+	for _, uri := range []string{"fetch://file:///etc/passwd", "other://https://example.com/", "fetch://example.com"} {
+		if _, _, err := parseResourceURI(uri); err == nil {
+			t.Errorf("expected %s to be rejected", uri)
+		}
+	}
+}
+
+func TestReadResource(t *testing.T) {
+	var version atomic.Int32
+	version.Store(1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, "<html><head><title>Page</title></head><body><p>version %d</p></body></html>", version.Load())
+	}))
+	defer ts.Close()
+
+	fs := newTestService(nil)
+	srv := server.NewMCPServer("test", "0.0.1", server.WithResourceCapabilities(true, true))
+	for rt, rtf := range fs.ResourceTemplates() {
+		srv.AddResourceTemplate(rt, rtf)
+	}
+	session := &testSession{notifications: make(chan mcp.JSONRPCNotification, 10)}
+	ctx := srv.WithContext(context.Background(), session)
+
+	send := func(method, params string) string {
+		t.Helper()
+		msg := fmt.Sprintf(`{"jsonrpc": "2.0", "id": 1, "method": %q, "params": %s}`, method, params)
+		data, err := json.Marshal(srv.HandleMessage(ctx, json.RawMessage(msg)))
+		if err != nil {
+			t.Fatalf("failed to encode response: %v", err)
+		}
+		return string(data)
+	}
+
+	uri := "fetch-md://" + ts.URL + "/page"
+	read := fmt.Sprintf(`{"uri": %q}`, uri)
+	if resp := send("resources/read", read); !strings.Contains(resp, "version 1") || !strings.Contains(resp, "text/markdown") {
+		t.Fatalf("unexpected read response: %s", resp)
+	}
+	// Pages are served through the templates, they are not listed one by one.
+	if resp := send("resources/list", "{}"); strings.Contains(resp, ts.URL) {
+		t.Errorf("expected fetched page not to be listed: %s", resp)
+	}
+
+	// A refresh with new content notifies the session that read the page.
+	version.Store(2)
+	fs.resources.ttl = 0
+	if resp := send("resources/read", read); !strings.Contains(resp, "version 2") {
+		t.Fatalf("expected refreshed content: %s", resp)
+	}
+	n := <-session.notifications
+	if n.Method != "notifications/resources/updated" || n.Params.AdditionalFields["uri"] != uri {
+		t.Errorf("unexpected notification: %+v", n)
+	}
+
+	// A session whose notifications cannot be delivered is dropped.
+	fs.resources.subscribe(uri, &blockedSession{})
+	version.Store(3)
+	if resp := send("resources/read", read); !strings.Contains(resp, "version 3") {
+		t.Fatalf("expected refreshed content: %s", resp)
+	}
+	<-session.notifications
+	if sessions := fs.resources.sessions(uri); len(sessions) != 1 || sessions[0] != session {
+		t.Errorf("expected the blocked session to be unsubscribed, got %v", sessions)
+	}
+}
+
+func TestResourceCacheEviction(t *testing.T) {
+	rc := newResourceCache()
+	first := "https://example.com/0"
+	for i := 0; i < maxCachedPages; i++ {
+		rc.put(&cachedPage{url: fmt.Sprintf("https://example.com/%d", i)})
+	}
+	rc.subscribe(schemeRaw+first, &testSession{})
+	rc.put(&cachedPage{url: "https://example.com/new"})
+	if _, ok := rc.pages[first]; ok || len(rc.sessions(schemeRaw+first)) > 0 {
+		t.Errorf("evicted page is still tracked")
+	}
+	if len(rc.pages) != maxCachedPages {
+		t.Errorf("expected %d cached pages, got %d", maxCachedPages, len(rc.pages))
+	}
+}
@@ -0,0 +1,285 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dyike/MonoMCPHub/internal/fetch/config"
+	"github.com/dyike/MonoMCPHub/pkg/httpclient"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+const (
+	// maxFetchedContent caps the Markdown kept per auto-fetched result
+	maxFetchedContent = 20000
+
+	maxFetchTop = 5
+)
+
+// SearchOptions are the provider independent parameters of a search
+type SearchOptions struct {
+	Page       int
+	Language   string
+	TimeRange  string
+	SafeSearch int
+}
+
+// SearchResult is a normalized search hit, optionally with the fetched page
+type SearchResult struct {
+	Title      string `json:"title"`
+	URL        string `json:"url"`
+	Snippet    string `json:"snippet,omitempty"`
+	Published  string `json:"published,omitempty"`
+	Source     string `json:"source,omitempty"`
+	Content    string `json:"content,omitempty"`
+	FetchError string `json:"fetch_error,omitempty"`
+}
+
+// SearchResults is one page of results
+type SearchResults struct {
+	Query       string          `json:"query"`
+	Page        int             `json:"page"`
+	Results     []*SearchResult `json:"results"`
+	Suggestions []string        `json:"suggestions,omitempty"`
+}
+
+// SearchProvider is a web search backend
+type SearchProvider interface {
+	Name() string
+	Search(ctx context.Context, query string, opts SearchOptions) (*SearchResults, error)
+}
+
+// searchProviders creates the configured provider by name
+var searchProviders = map[string]func(cfg config.SearchConfig, client *http.Client) (SearchProvider, error){
+	"searxng": newSearXNG,
+}
+
+func newSearchProvider(cfg *config.FetchConfig) (SearchProvider, error) {
+	if cfg.Search.Provider == "" {
+		return nil, nil
+	}
+	factory, ok := searchProviders[cfg.Search.Provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown search provider: %s", cfg.Search.Provider)
+	}
+	// The search instance is chosen by the operator and often runs locally,
+	// so it is reached without the private network guard.
+	client, err := httpclient.NewClient(httpclient.Resolve(cfg.HTTP), time.Duration(cfg.Timeout)*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return factory(cfg.Search, client)
+}
+
+// searXNG queries the JSON API of a SearXNG instance, which needs "json" in
+// its search.formats setting.
+type searXNG struct {
+	base       *url.URL
+	client     *http.Client
+	engines    []string
+	categories []string
+}
+
+func newSearXNG(cfg config.SearchConfig, client *http.Client) (SearchProvider, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("searxng needs a url")
+	}
+	base, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid searxng url: %v", err)
+	}
+	return &searXNG{
+		base:       base,
+		client:     client,
+		engines:    cfg.Engines,
+		categories: cfg.Categories,
+	}, nil
+}
+
+func (s *searXNG) Name() string {
+	return "searxng"
+}
+
+func (s *searXNG) Search(ctx context.Context, query string, opts SearchOptions) (*SearchResults, error) {
+	u := s.base.JoinPath("search")
+	q := url.Values{}
+	q.Set("q", query)
+	q.Set("format", "json")
+	q.Set("pageno", strconv.Itoa(opts.Page))
+	q.Set("safesearch", strconv.Itoa(opts.SafeSearch))
+	if opts.Language != "" {
+		q.Set("language", opts.Language)
+	}
+	if opts.TimeRange != "" {
+		q.Set("time_range", opts.TimeRange)
+	}
+	if len(s.engines) > 0 {
+		q.Set("engines", strings.Join(s.engines, ","))
+	}
+	if len(s.categories) > 0 {
+		q.Set("categories", strings.Join(s.categories, ","))
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, redactURLError(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("searxng returned %s", resp.Status)
+	}
+
+	var body struct {
+		Results []struct {
+			Title         string `json:"title"`
+			URL           string `json:"url"`
+			Content       string `json:"content"`
+			Engine        string `json:"engine"`
+			PublishedDate string `json:"publishedDate"`
+		} `json:"results"`
+		Suggestions []string `json:"suggestions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("invalid searxng response: %v", err)
+	}
+
+	results := &SearchResults{
+		Query:       query,
+		Page:        opts.Page,
+		Results:     make([]*SearchResult, 0, len(body.Results)),
+		Suggestions: body.Suggestions,
+	}
+	for _, r := range body.Results {
+		results.Results = append(results.Results, &SearchResult{
+			Title:     strings.TrimSpace(r.Title),
+			URL:       r.URL,
+			Snippet:   strings.TrimSpace(r.Content),
+			Published: r.PublishedDate,
+			Source:    r.Engine,
+		})
+	}
+	return results, nil
+}
+
+func newSearchTool() mcp.Tool {
+	return mcp.NewTool("web_search",
+		mcp.WithDescription("Search the web and return results with title, url and snippet, optionally fetching the top results as Markdown"),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("The search query"),
+		),
+		mcp.WithNumber("page",
+			mcp.Description("The result page, starting at 1"),
+			mcp.DefaultNumber(1),
+		),
+		mcp.WithNumber("limit",
+			mcp.Description("Maximum number of results to return from the page"),
+			mcp.DefaultNumber(10),
+		),
+		mcp.WithString("language",
+			mcp.Description("Language code of the results, e.g. en or de-CH"),
+		),
+		mcp.WithString("time_range",
+			mcp.Description("Only return results from this time range"),
+			mcp.Enum("day", "week", "month", "year"),
+		),
+		mcp.WithNumber("safe_search",
+			mcp.Description("Safe search level: 0 off, 1 moderate, 2 strict"),
+			mcp.DefaultNumber(1),
+		),
+		mcp.WithNumber("fetch_top",
+			mcp.Description(fmt.Sprintf("Fetch the first N results (at most %d) and include their content as Markdown", maxFetchTop)),
+			mcp.DefaultNumber(0),
+		),
+	)
+}
+
+func (fs *FetchService) handleSearch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	query, ok := args["query"].(string)
+	if !ok || strings.TrimSpace(query) == "" {
+		return mcp.NewToolResultError("query must be a non-empty string"), nil
+	}
+	opts := SearchOptions{
+		Page:       max(intArg(args, "page", 1), 1),
+		SafeSearch: min(max(intArg(args, "safe_search", 1), 0), 2),
+	}
+	opts.Language, _ = args["language"].(string)
+	opts.TimeRange, _ = args["time_range"].(string)
+	limit := intArg(args, "limit", 10)
+	fetchTop := min(max(intArg(args, "fetch_top", 0), 0), maxFetchTop)
+
+	results, err := fs.search.Search(ctx, query, opts)
+	if err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Search failed: %v", err)), nil
+	}
+	if limit > 0 && len(results.Results) > limit {
+		results.Results = results.Results[:limit]
+	}
+	fs.fetchResults(ctx, results.Results[:min(fetchTop, len(results.Results))])
+
+	payload, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(string(payload)), nil
+}
+
+// fetchResults fills in the content of results through the fetch_url pipeline
+func (fs *FetchService) fetchResults(ctx context.Context, results []*SearchResult) {
+	var wg sync.WaitGroup
+	for _, r := range results {
+		wg.Add(1)
+		go func(r *SearchResult) {
+			defer wg.Done()
+			content, err := fs.fetchMarkdown(ctx, r.URL)
+			if err != nil {
+				r.FetchError = err.Error()
+				return
+			}
+			if len(content) > maxFetchedContent {
+				content = string(truncateUTF8([]byte(content), maxFetchedContent)) + "\n\n[truncated]"
+			}
+			r.Content = content
+		}(r)
+	}
+	wg.Wait()
+}
+
+// fetchMarkdown fetches a URL and returns its text as fetch_url renders it
+func (fs *FetchService) fetchMarkdown(ctx context.Context, rawURL string) (string, error) {
+	resp, body, err := fs.get(ctx, rawURL)
+	if err != nil {
+		return "", redactURLError(err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", fmt.Errorf("HTTP error: %s", resp.Status)
+	}
+	result, err := renderResponse(resp, body, fetchOptions{BaseURL: resp.Request.URL})
+	if err != nil {
+		return "", err
+	}
+	var text strings.Builder
+	for _, c := range result.Content {
+		if tc, ok := c.(mcp.TextContent); ok {
+			text.WriteString(tc.Text)
+		}
+	}
+	if result.IsError {
+		return "", fmt.Errorf("%s", text.String())
+	}
+	return text.String(), nil
+}
@@ -0,0 +1,86 @@
+package fetch
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dyike/MonoMCPHub/internal/fetch/config"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestWebSearch(t *testing.T) {
+	site := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html><body><p>Go is an open source language.</p></body></html>"))
+	}))
+	defer site.Close()
+
+	var gotQuery string
+	searx := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search" || r.URL.Query().Get("format") != "json" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		gotQuery = r.URL.RawQuery
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"results": []map[string]string{
+				{"title": " Go ", "url": site.URL + "/go", "content": "The Go language", "engine": "duckduckgo"},
+				{"title": "Missing", "url": site.URL + "/missing", "content": "gone"},
+				{"title": "Third", "url": site.URL + "/third"},
+			},
+			"suggestions": []string{"golang"},
+		})
+	}))
+	defer searx.Close()
+
+	cfg := config.NewFetchConfig()
+	cfg.Search = config.SearchConfig{Provider: "searxng", URL: searx.URL}
+	fs := newTestService(cfg)
+
+	result, err := fs.handleSearch(context.Background(), newFetchRequest(map[string]interface{}{
+		"query":     "golang",
+		"page":      float64(2),
+		"limit":     float64(2),
+		"fetch_top": float64(2),
+	}))
+	if err != nil || result.IsError {
+		t.Fatalf("web_search failed: %v %v", err, result)
+	}
+	if gotQuery != "format=json&pageno=2&q=golang&safesearch=1" {
+		t.Errorf("unexpected searxng query: %s", gotQuery)
+	}
+
+	var results SearchResults
+	if err := json.Unmarshal([]byte(result.Content[0].(mcp.TextContent).Text), &results); err != nil {
+		t.Fatalf("invalid result: %v", err)
+	}
+	if len(results.Results) != 2 || results.Page != 2 || results.Suggestions[0] != "golang" {
+		t.Fatalf("unexpected results: %+v", results)
+	}
+	first, second := results.Results[0], results.Results[1]
+	if first.Title != "Go" || first.Snippet != "The Go language" || first.Source != "duckduckgo" {
+		t.Errorf("unexpected first result: %+v", first)
+	}
+	if first.Content != "Go is an open source language.\n\n" {
+		t.Errorf("expected fetched content, got %q", first.Content)
+	}
+	if second.Content != "" || second.FetchError == "" {
+		t.Errorf("expected failed fetch to be reported per result: %+v", second)
+	}
+}
+
+func TestSearchToolNeedsProvider(t *testing.T) {
+	fs := newTestService(nil)
+	for _, tool := range fs.Tools() {
+		if tool.Tool.Name == "web_search" {
+			t.Errorf("web_search should not be offered without a provider")
+		}
+	}
+}
@@ -3,21 +3,19 @@ package fetch
 import (
 	"context"
 	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
+	neturl "net/url"
 	"regexp"
-	"strings"
+	"sync"
+	"time"
 
-	"github.com/PuerkitoBio/goquery"
+	"github.com/dyike/MonoMCPHub/internal/fetch/config"
 	sv "github.com/dyike/MonoMCPHub/pkg/service"
 	"github.com/kkdai/youtube/v2"
 	"github.com/mark3labs/mcp-go/mcp"
 )
 
-const (
-	userAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_4) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/85.0.4183.83 Safari/537.36,gzip(gfe)"
-)
-
 var (
 	reXMLTranscript = regexp.MustCompile(`<text start="([^"]*)" dur="([^"]*)">([^<]*)</text>`)
 )
@@ -45,19 +43,43 @@ type CaptionsData struct {
 
 type FetchService struct {
 	sv.ServiceManager
+	config        *config.FetchConfig
 	client        *http.Client
+	guard         *guard
 	youtubeClient *youtube.Client
+	renderer      *renderer
+	feeds         *feedReader
+	resources     *resourceCache
+	watcher       *watcher
+	store         *store
+	search        SearchProvider
+
+	profilesMu sync.Mutex
+	profiles   map[string]*fetchProfile
 }
 
-func NewFetchService(ctx context.Context) *FetchService {
+func NewFetchService(ctx context.Context, cfg *config.FetchConfig) *FetchService {
+	if cfg == nil {
+		cfg = config.NewFetchConfig()
+	}
+	g := newGuard(cfg)
+	st := newStore(cfg.DataPath)
 	fs := &FetchService{
-		client:        &http.Client{},
+		config:        cfg,
+		client:        g.newClient(cfg),
+		guard:         g,
 		youtubeClient: &youtube.Client{},
+		renderer:      newRenderer(cfg, g),
+		feeds:         newFeedReader(st),
+		resources:     newResourceCache(),
+		watcher:       newWatcher(st),
+		store:         st,
+		profiles:      make(map[string]*fetchProfile),
 	}
 	fs.ServiceManager = *sv.NewServiceManager(ctx)
 
 	fs.AddTool(mcp.NewTool("fetch_url",
-		mcp.WithDescription("Fetch the content of a URL, can return HTML or Markdown (default)"),
+		mcp.WithDescription("Fetch the content of a URL. HTML pages are returned as Markdown (default) or HTML, PDFs as extracted text, JSON pretty-printed, plain text as is and images as image content"),
 		mcp.WithString("url",
 			mcp.Required(),
 			mcp.Description("The URL to fetch"),
@@ -66,8 +88,43 @@ func NewFetchService(ctx context.Context) *FetchService {
 			mcp.Description("Return the content as HTML"),
 			mcp.DefaultBool(false),
 		),
+		mcp.WithString("jsonpath",
+			mcp.Description("JSONPath expression used to filter JSON responses, e.g. $.items[*].name"),
+		),
+		mcp.WithString("render",
+			mcp.Description("Render the page in a headless browser: auto renders only pages that come back nearly empty"),
+			mcp.DefaultString(renderAuto),
+			mcp.Enum(renderAuto, renderAlways, renderNever),
+		),
+		mcp.WithString("wait_selector",
+			mcp.Description("CSS selector to wait for when rendering, instead of waiting for the network to go idle"),
+		),
+		profileArg(fs.profileNames()),
+		mcp.WithBoolean("metadata",
+			mcp.Description("Start Markdown output with a header of the page title, canonical URL, author, date, language and description"),
+			mcp.DefaultBool(true),
+		),
 	), fs.handleFetchURL)
 
+	fs.AddTool(newHTTPRequestTool(), fs.handleHTTPRequest)
+	fs.AddTool(newCrawlTool(), fs.handleCrawl)
+	fs.AddTool(newExtractTool(), fs.handleExtract)
+	fs.AddTool(newFeedTool(), fs.handleFeed)
+	fs.AddTool(newMetadataTool(), fs.handleMetadata)
+	fs.AddTool(fs.newManyTool(), fs.handleFetchMany)
+
+	search, err := newSearchProvider(cfg)
+	if err != nil {
+		slog.Error("Failed to set up web search", "error", err)
+	} else if search != nil {
+		fs.search = search
+		fs.AddTool(newSearchTool(), fs.handleSearch)
+	}
+
+	fs.registerResources()
+	fs.registerWatchTools()
+	go fs.runWatches()
+
 	return fs
 }
 
@@ -80,64 +137,150 @@ func (fs *FetchService) handleFetchURL(ctx context.Context, request mcp.CallTool
 	if !ok {
 		asHTML = false
 	}
+	jsonPath, _ := request.Params.Arguments["jsonpath"].(string)
+	render, ok := request.Params.Arguments["render"].(string)
+	if !ok || render == "" {
+		render = renderAuto
+	}
+	waitSelector, _ := request.Params.Arguments["wait_selector"].(string)
+	metadata, ok := request.Params.Arguments["metadata"].(bool)
+	if !ok {
+		metadata = true
+	}
+	profileName, _ := request.Params.Arguments["profile"].(string)
+	profile, err := fs.profile(ctx, profileName)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	opts := fetchOptions{
+		AsHTML:   asHTML,
+		JSONPath: jsonPath,
+		Metadata: metadata,
+	}
+
+	if render == renderAlways {
+		if profile != nil {
+			return mcp.NewToolResultError("render=always cannot be combined with a profile, the browser does not use its cookies or credentials"), nil
+		}
+		result, err := fs.renderURL(ctx, url, waitSelector, opts)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		return result, nil
+	}
 
 	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
+	resp, body, err := fs.doAs(req, profile)
+	if err != nil {
+		return nil, redactURLError(err)
+	}
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return httpErrorResult(resp, body), nil
+	}
+	// Pages fetched with a profile may be private, keep them out of the
+	// shared resource cache and the unauthenticated browser.
+	if profile == nil {
+		fs.cachePage(ctx, url, resp, body)
+	}
+
+	if render == renderAuto && profile == nil && needsRendering(resp, body) {
+		result, err := fs.renderURL(ctx, url, waitSelector, opts)
+		if err == nil {
+			return result, nil
+		}
+		slog.Warn("Falling back to the unrendered page", "url", url, "error", err)
+	}
+
+	opts.BaseURL = resp.Request.URL
+	return renderResponse(resp, body, opts)
+}
 
-	resp, err := fs.client.Do(req)
+// get performs a GET request and reads the whole response body
+func (fs *FetchService) get(ctx context.Context, url string) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	defer resp.Body.Close()
+	return fs.do(req)
+}
 
-	body, err := io.ReadAll(resp.Body)
+// do sends a request through the guarded client and reads the whole response body
+func (fs *FetchService) do(req *http.Request) (*http.Response, []byte, error) {
+	return fs.doAs(req, nil)
+}
+
+// doAs is do with the cookie jar and credentials of a profile, if not nil
+func (fs *FetchService) doAs(req *http.Request, profile *fetchProfile) (*http.Response, []byte, error) {
+	if err := fs.guard.checkTarget(req.Context(), req.URL); err != nil {
+		return nil, nil, err
+	}
+
+	client := fs.client
+	if profile != nil {
+		if err := profile.apply(req); err != nil {
+			return nil, nil, err
+		}
+		client = profile.client
+	}
+	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	defer resp.Body.Close()
 
-	if asHTML {
-		return &mcp.CallToolResult{
-			Content: []mcp.Content{
-				mcp.TextContent{
-					Type: "html",
-					Text: string(body),
-				},
-			},
-		}, nil
+	body, err := fs.guard.readBody(resp.Body)
+	if err != nil {
+		return nil, nil, err
 	}
+	return resp, body, nil
+}
 
-	// Convert HTML to Markdown
-	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
+// renderURL loads url in the headless browser and runs the rendered DOM
+// through the same HTML pipeline as plain fetches.
+func (fs *FetchService) renderURL(ctx context.Context, rawURL, waitSelector string, opts fetchOptions) (*mcp.CallToolResult, error) {
+	// The renderer vets the requests Chrome makes itself, checking the URL
+	// first just gives a clearer error.
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.guard.vetURL(ctx, u); err != nil {
+		return nil, err
+	}
+	html, location, err := fs.renderer.render(ctx, fs.Ctx(), rawURL, waitSelector, time.Duration(fs.config.Timeout)*time.Second)
 	if err != nil {
 		return nil, err
 	}
+	// Links are relative to where the page ended up after client-side
+	// redirects, not to the URL asked for.
+	opts.BaseURL = u
+	if final, err := neturl.Parse(location); err == nil && final.IsAbs() {
+		opts.BaseURL = final
+	}
+	return renderHTML([]byte(html), opts)
+}
+
+// needsRendering reports whether an HTML response looks like an empty shell
+// that only gets its content from JavaScript.
+func needsRendering(resp *http.Response, body []byte) bool {
+	contentType := resp.Header.Get("Content-Type")
+	if kind, _ := detectContentKind(contentType, body); kind != kindHTML {
+		return false
+	}
+	decoded, err := decodeBody(body, contentType)
+	if err != nil {
+		return false
+	}
+	return isNearlyEmpty(decoded)
+}
 
-	// Basic HTML to Markdown conversion
-	var markdown strings.Builder
-	doc.Find("body").Each(func(i int, s *goquery.Selection) {
-		s.Find("h1,h2,h3,h4,h5,h6").Each(func(i int, h *goquery.Selection) {
-			markdown.WriteString("#" + strings.Repeat("#", i))
-			markdown.WriteString(" " + h.Text() + "\n\n")
-		})
-		s.Find("p").Each(func(i int, p *goquery.Selection) {
-			markdown.WriteString(p.Text() + "\n\n")
-		})
-		s.Find("a").Each(func(i int, a *goquery.Selection) {
-			href, exists := a.Attr("href")
-			if exists {
-				markdown.WriteString("[" + a.Text() + "](" + href + ")\n")
-			}
-		})
-	})
-
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			mcp.TextContent{
-				Type: "text",
-				Text: markdown.String(),
-			},
-		},
-	}, nil
+func (fs *FetchService) Close() error {
+	fs.watcher.stop()
+	fs.renderer.Close()
+	return nil
 }
@@ -0,0 +1,58 @@
+package fetch
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// store persists small pieces of service state as JSON files in the data directory
+type store struct {
+	mu  sync.Mutex
+	dir string
+}
+
+func newStore(dir string) *store {
+	return &store{dir: dir}
+}
+
+// load decodes the named state file into v, leaving v untouched if it does not exist yet
+func (s *store) load(name string, v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := os.ReadFile(filepath.Join(s.dir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", name, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", name, err)
+	}
+	return nil
+}
+
+// save atomically replaces the named state file with v. Files are private to
+// the user since they may hold cookies.
+func (s *store) save(name string, v interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create data directory: %v", err)
+	}
+	path := filepath.Join(s.dir, name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %v", name, err)
+	}
+	return os.Rename(tmp, path)
+}
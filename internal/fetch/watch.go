@@ -0,0 +1,517 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/pmezard/go-difflib/difflib"
+	nethtml "golang.org/x/net/html"
+)
+
+const (
+	watchStateFile = "watches.json"
+	schemeWatch    = "fetch-watch://"
+
+	// watchTick is how often the scheduler looks for watches that are due
+	watchTick = 30 * time.Second
+
+	// maxSnapshots bounds the history kept per watch
+	maxSnapshots = 20
+
+	defaultWatchInterval = 60
+	minWatchInterval     = 1
+)
+
+// blockElements start a new line in the text of a snapshot
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "br": true, "dd": true,
+	"div": true, "dl": true, "dt": true, "fieldset": true, "figcaption": true, "figure": true,
+	"footer": true, "form": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true,
+	"h6": true, "header": true, "hr": true, "li": true, "main": true, "nav": true, "ol": true,
+	"p": true, "pre": true, "section": true, "table": true, "td": true, "th": true, "tr": true, "ul": true,
+}
+
+// Watch is a URL, optionally scoped by a CSS selector, that is snapshotted on a schedule
+type Watch struct {
+	ID          string     `json:"id"`
+	URL         string     `json:"url"`
+	Selector    string     `json:"selector,omitempty"`
+	Interval    int        `json:"interval_minutes"`
+	Created     time.Time  `json:"created"`
+	LastChecked time.Time  `json:"last_checked,omitempty"`
+	LastChanged time.Time  `json:"last_changed,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	Snapshots   []Snapshot `json:"snapshots,omitempty"`
+}
+
+// Snapshot is the extracted text of a watched page at one point in time
+type Snapshot struct {
+	Time time.Time `json:"time"`
+	Hash string    `json:"hash"`
+	Text string    `json:"text"`
+}
+
+func (w *Watch) due(now time.Time) bool {
+	return now.Sub(w.LastChecked) >= time.Duration(w.Interval)*time.Minute
+}
+
+func (w *Watch) uri() string {
+	return schemeWatch + w.ID
+}
+
+// watcher owns the registered watches and runs their scheduled snapshots
+type watcher struct {
+	mu      sync.Mutex
+	store   *store
+	watches map[string]*Watch
+	// srv is the server seen on the last request, scheduled snapshots need it
+	// to send notifications outside of any request.
+	srv *server.MCPServer
+
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func newWatcher(s *store) *watcher {
+	w := &watcher{
+		store:   s,
+		watches: make(map[string]*Watch),
+		done:    make(chan struct{}),
+	}
+	if err := s.load(watchStateFile, &w.watches); err != nil {
+		slog.Warn("Failed to load watches", "error", err)
+	}
+	return w
+}
+
+// stop ends the scheduler started by runWatches
+func (w *watcher) stop() {
+	w.stopOnce.Do(func() { close(w.done) })
+}
+
+func (w *watcher) save() error {
+	return w.store.save(watchStateFile, w.watches)
+}
+
+func watchID(url, selector string) string {
+	sum := sha1.Sum([]byte(url + "\n" + selector))
+	return hex.EncodeToString(sum[:4])
+}
+
+// runWatches snapshots due watches until the service is closed
+func (fs *FetchService) runWatches() {
+	ticker := time.NewTicker(watchTick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-fs.Ctx().Done():
+			return
+		case <-fs.watcher.done:
+			return
+		case <-ticker.C:
+		}
+
+		fs.watcher.mu.Lock()
+		var due []string
+		now := time.Now()
+		for id, w := range fs.watcher.watches {
+			if w.due(now) {
+				due = append(due, id)
+			}
+		}
+		fs.watcher.mu.Unlock()
+
+		for _, id := range due {
+			if _, err := fs.checkWatch(fs.Ctx(), id); err != nil {
+				slog.Warn("Failed to check watch", "id", id, "error", err)
+			}
+		}
+	}
+}
+
+// checkWatch takes a new snapshot and reports whether the content changed.
+// Subscribers of the watch resource are notified of changes.
+func (fs *FetchService) checkWatch(ctx context.Context, id string) (bool, error) {
+	fs.watcher.mu.Lock()
+	w, ok := fs.watcher.watches[id]
+	if !ok {
+		fs.watcher.mu.Unlock()
+		return false, fmt.Errorf("unknown watch: %s", id)
+	}
+	target, selector := w.URL, w.Selector
+	fs.watcher.mu.Unlock()
+
+	text, fetchErr := fs.watchText(ctx, target, selector)
+
+	fs.watcher.mu.Lock()
+	defer fs.watcher.mu.Unlock()
+	w, ok = fs.watcher.watches[id]
+	if !ok {
+		return false, fmt.Errorf("unknown watch: %s", id)
+	}
+	now := time.Now()
+	w.LastChecked = now
+	w.LastError = ""
+	changed := false
+	if fetchErr != nil {
+		w.LastError = fetchErr.Error()
+	} else {
+		sum := sha256.Sum256([]byte(text))
+		hash := hex.EncodeToString(sum[:])
+		if n := len(w.Snapshots); n == 0 || w.Snapshots[n-1].Hash != hash {
+			changed = n > 0
+			w.Snapshots = append(w.Snapshots, Snapshot{Time: now, Hash: hash, Text: text})
+			if len(w.Snapshots) > maxSnapshots {
+				w.Snapshots = w.Snapshots[len(w.Snapshots)-maxSnapshots:]
+			}
+			if changed {
+				w.LastChanged = now
+			}
+		}
+	}
+	if err := fs.watcher.save(); err != nil {
+		return changed, err
+	}
+
+	if changed && fs.watcher.srv != nil {
+		srv := fs.watcher.srv
+		for _, session := range fs.resources.sessions(w.uri()) {
+			err := srv.SendNotificationToClient(srv.WithContext(fs.Ctx(), session), "notifications/resources/updated", map[string]interface{}{
+				"uri": w.uri(),
+			})
+			if err != nil {
+				fs.resources.unsubscribe(w.uri(), session)
+			}
+		}
+	}
+	return changed, fetchErr
+}
+
+// watchText fetches a page and returns its visible text, scoped to the
+// elements matching selector when one is given.
+func (fs *FetchService) watchText(ctx context.Context, target, selector string) (string, error) {
+	resp, body, err := fs.get(ctx, target)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", fmt.Errorf("HTTP error: %s", resp.Status)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	body, err = decodeBody(body, contentType)
+	if err != nil {
+		return "", err
+	}
+	if kind, _ := detectContentKind(contentType, body); kind != kindHTML {
+		if selector != "" {
+			return "", fmt.Errorf("selector needs an HTML page, got %s", contentType)
+		}
+		return string(body), nil
+	}
+
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	sel := doc.Find("body")
+	if selector != "" {
+		sel = doc.Find(selector)
+		if sel.Length() == 0 {
+			return "", fmt.Errorf("selector %q matched nothing", selector)
+		}
+	}
+	return visibleText(sel), nil
+}
+
+// visibleText renders a selection as text with one line per block element
+func visibleText(sel *goquery.Selection) string {
+	var b strings.Builder
+	var walk func(n *nethtml.Node)
+	walk = func(n *nethtml.Node) {
+		switch n.Type {
+		case nethtml.TextNode:
+			b.WriteString(n.Data)
+			return
+		case nethtml.ElementNode:
+			switch n.Data {
+			case "script", "style", "noscript", "template":
+				return
+			}
+		}
+		block := n.Type == nethtml.ElementNode && blockElements[n.Data]
+		if block {
+			b.WriteString("\n")
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+		if block {
+			b.WriteString("\n")
+		}
+	}
+	for _, n := range sel.Nodes {
+		walk(n)
+		b.WriteString("\n")
+	}
+
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// rememberServer keeps the server and subscribes the calling session to a watch
+func (fs *FetchService) rememberServer(ctx context.Context, w *Watch) {
+	if srv := server.ServerFromContext(ctx); srv != nil {
+		fs.watcher.mu.Lock()
+		fs.watcher.srv = srv
+		fs.watcher.mu.Unlock()
+	}
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		fs.resources.subscribe(w.uri(), session)
+	}
+}
+
+func (fs *FetchService) registerWatchTools() {
+	fs.AddTool(mcp.NewTool("fetch_watch",
+		mcp.WithDescription("Watch a URL for changes. The page text, optionally scoped by a CSS selector, is snapshotted on a schedule and kept locally. Changes are announced as updates of the fetch-watch:// resource"),
+		mcp.WithString("url",
+			mcp.Required(),
+			mcp.Description("The URL to watch"),
+		),
+		mcp.WithString("selector",
+			mcp.Description("CSS selector limiting the watched part of the page"),
+		),
+		mcp.WithNumber("interval_minutes",
+			mcp.Description("Minutes between snapshots"),
+			mcp.DefaultNumber(defaultWatchInterval),
+		),
+	), fs.handleWatch)
+
+	fs.AddTool(mcp.NewTool("fetch_unwatch",
+		mcp.WithDescription("Stop watching a URL and delete its snapshots"),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("The watch id"),
+		),
+	), fs.handleUnwatch)
+
+	fs.AddTool(mcp.NewTool("fetch_watch_list",
+		mcp.WithDescription("List watched URLs with their schedule and last change"),
+	), fs.handleWatchList)
+
+	fs.AddTool(mcp.NewTool("fetch_watch_diff",
+		mcp.WithDescription("Show a unified diff between two snapshots of a watched URL, by default the latest two"),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("The watch id"),
+		),
+		mcp.WithBoolean("check_now",
+			mcp.Description("Take a new snapshot before diffing"),
+			mcp.DefaultBool(false),
+		),
+		mcp.WithNumber("from",
+			mcp.Description("Index of the older snapshot, negative values count from the latest (-2 is the one before the latest)"),
+			mcp.DefaultNumber(-2),
+		),
+		mcp.WithNumber("to",
+			mcp.Description("Index of the newer snapshot, negative values count from the latest"),
+			mcp.DefaultNumber(-1),
+		),
+	), fs.handleWatchDiff)
+
+	fs.AddResourceTemplateHandler(mcp.NewResourceTemplate(schemeWatch+"{id}", "Watched page",
+		mcp.WithTemplateDescription("The latest snapshot of a URL registered with fetch_watch"),
+		mcp.WithTemplateMIMEType("text/plain"),
+	), fs.handleReadWatch)
+}
+
+func (fs *FetchService) handleWatch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	target, ok := args["url"].(string)
+	if !ok || target == "" {
+		return mcp.NewToolResultError("url must be a string"), nil
+	}
+	selector, _ := args["selector"].(string)
+	interval := intArg(args, "interval_minutes", defaultWatchInterval)
+	if interval < minWatchInterval {
+		interval = minWatchInterval
+	}
+
+	id := watchID(target, selector)
+	fs.watcher.mu.Lock()
+	w, ok := fs.watcher.watches[id]
+	if !ok {
+		w = &Watch{ID: id, URL: target, Selector: selector, Created: time.Now()}
+		fs.watcher.watches[id] = w
+	}
+	w.Interval = interval
+	fs.watcher.mu.Unlock()
+	fs.rememberServer(ctx, w)
+
+	if _, err := fs.checkWatch(ctx, id); err != nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Watch %s registered, but the first snapshot failed: %v", id, err)), nil
+	}
+	return fs.watchSummary(id)
+}
+
+func (fs *FetchService) handleUnwatch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, _ := request.Params.Arguments["id"].(string)
+
+	fs.watcher.mu.Lock()
+	defer fs.watcher.mu.Unlock()
+	w, ok := fs.watcher.watches[id]
+	if !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("Unknown watch: %s", id)), nil
+	}
+	delete(fs.watcher.watches, id)
+	fs.resources.forget(w.uri())
+	if err := fs.watcher.save(); err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Stopped watching %s", id)), nil
+}
+
+func (fs *FetchService) handleWatchList(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	type watchInfo struct {
+		ID          string    `json:"id"`
+		URL         string    `json:"url"`
+		Selector    string    `json:"selector,omitempty"`
+		Interval    int       `json:"interval_minutes"`
+		Snapshots   int       `json:"snapshots"`
+		LastChecked time.Time `json:"last_checked,omitempty"`
+		LastChanged time.Time `json:"last_changed,omitempty"`
+		LastError   string    `json:"last_error,omitempty"`
+	}
+
+	fs.watcher.mu.Lock()
+	list := make([]watchInfo, 0, len(fs.watcher.watches))
+	for _, w := range fs.watcher.watches {
+		list = append(list, watchInfo{
+			ID:          w.ID,
+			URL:         w.URL,
+			Selector:    w.Selector,
+			Interval:    w.Interval,
+			Snapshots:   len(w.Snapshots),
+			LastChecked: w.LastChecked,
+			LastChanged: w.LastChanged,
+			LastError:   w.LastError,
+		})
+	}
+	fs.watcher.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].URL < list[j].URL })
+
+	payload, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return mcp.NewToolResultText(string(payload)), nil
+}
+
+func (fs *FetchService) handleWatchDiff(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args := request.Params.Arguments
+	id, _ := args["id"].(string)
+
+	fs.watcher.mu.Lock()
+	w, ok := fs.watcher.watches[id]
+	fs.watcher.mu.Unlock()
+	if !ok {
+		return mcp.NewToolResultError(fmt.Sprintf("Unknown watch: %s", id)), nil
+	}
+	fs.rememberServer(ctx, w)
+
+	if checkNow, _ := args["check_now"].(bool); checkNow {
+		if _, err := fs.checkWatch(ctx, id); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Failed to take a snapshot: %v", err)), nil
+		}
+	}
+
+	fs.watcher.mu.Lock()
+	snapshots := append([]Snapshot(nil), w.Snapshots...)
+	fs.watcher.mu.Unlock()
+
+	index := func(i int) int {
+		if i < 0 {
+			return len(snapshots) + i
+		}
+		return i
+	}
+	from, to := index(intArg(args, "from", -2)), index(intArg(args, "to", -1))
+	if len(snapshots) < 2 {
+		return mcp.NewToolResultText(fmt.Sprintf("Only %d snapshot(s) of %s so far, nothing to diff", len(snapshots), w.URL)), nil
+	}
+	if from < 0 || to < 0 || from >= len(snapshots) || to >= len(snapshots) {
+		return mcp.NewToolResultError(fmt.Sprintf("Snapshot index out of range, %d snapshots are kept", len(snapshots))), nil
+	}
+
+	diff, err := unifiedDiff(snapshots[from], snapshots[to])
+	if err != nil {
+		return nil, err
+	}
+	if diff == "" {
+		return mcp.NewToolResultText("No changes between the snapshots"), nil
+	}
+	return mcp.NewToolResultText(diff), nil
+}
+
+func unifiedDiff(a, b Snapshot) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(a.Text + "\n"),
+		B:        difflib.SplitLines(b.Text + "\n"),
+		FromFile: "snapshot",
+		FromDate: a.Time.Format(time.RFC3339),
+		ToFile:   "snapshot",
+		ToDate:   b.Time.Format(time.RFC3339),
+		Context:  3,
+	})
+}
+
+func (fs *FetchService) watchSummary(id string) (*mcp.CallToolResult, error) {
+	fs.watcher.mu.Lock()
+	defer fs.watcher.mu.Unlock()
+	// The watch may have been removed since it was created.
+	w, ok := fs.watcher.watches[id]
+	if !ok || w == nil {
+		return mcp.NewToolResultError(fmt.Sprintf("Unknown watch: %s", id)), nil
+	}
+	return mcp.NewToolResultText(fmt.Sprintf("Watching %s as %s every %d minute(s), %d snapshot(s) kept. Read %s for the latest snapshot.",
+		w.URL, w.ID, w.Interval, len(w.Snapshots), w.uri())), nil
+}
+
+func (fs *FetchService) handleReadWatch(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	uri := request.Params.URI
+	id := strings.TrimPrefix(uri, schemeWatch)
+
+	fs.watcher.mu.Lock()
+	w, ok := fs.watcher.watches[id]
+	text := ""
+	if ok && len(w.Snapshots) > 0 {
+		text = w.Snapshots[len(w.Snapshots)-1].Text
+	}
+	fs.watcher.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown watch: %s", id)
+	}
+	fs.rememberServer(ctx, w)
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{URI: uri, MIMEType: "text/plain", Text: text},
+	}, nil
+}
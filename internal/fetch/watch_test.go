@@ -0,0 +1,69 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/dyike/MonoMCPHub/internal/fetch/config"
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestVisibleText(t *testing.T) {
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(`<body><h1>Status</h1><script>x()</script>
+<ul><li>API: <b>up</b></li><li>Web:   down</li></ul><p>Last<br>updated</p></body>`))
+	want := "Status\nAPI: up\nWeb: down\nLast\nupdated"
+	if got := visibleText(doc.Find("body")); got != want {
+		t.Errorf("visibleText() = %q, want %q", got, want)
+	}
+}
+
+func TestWatchDiff(t *testing.T) {
+	var status atomic.Value
+	status.Store("up")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprintf(w, `<html><body><nav>menu</nav><div id="status"><p>API</p><p>%s</p></div></body></html>`, status.Load())
+	}))
+	defer ts.Close()
+
+	cfg := config.NewFetchConfig()
+	cfg.DataPath = t.TempDir()
+	fs := newTestService(cfg)
+	defer fs.Close()
+
+	call := func(handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error), args map[string]interface{}) string {
+		t.Helper()
+		result, err := handler(context.Background(), newFetchRequest(args))
+		if err != nil || result.IsError {
+			t.Fatalf("tool call failed: %v %v", err, result)
+		}
+		return result.Content[0].(mcp.TextContent).Text
+	}
+
+	id := watchID(ts.URL, "#status")
+	if text := call(fs.handleWatch, map[string]interface{}{"url": ts.URL, "selector": "#status"}); !strings.Contains(text, id) {
+		t.Fatalf("expected watch id in %q", text)
+	}
+	if text := call(fs.handleWatchDiff, map[string]interface{}{"id": id, "check_now": true}); !strings.Contains(text, "nothing to diff") {
+		t.Errorf("expected no diff for an unchanged page, got %q", text)
+	}
+
+	status.Store("down")
+	diff := call(fs.handleWatchDiff, map[string]interface{}{"id": id, "check_now": true})
+	if !strings.Contains(diff, "-up\n+down\n") || strings.Contains(diff, "menu") {
+		t.Errorf("unexpected diff: %q", diff)
+	}
+
+	// Snapshots are persisted and picked up by a new service.
+	fs = newTestService(cfg)
+	defer fs.Close()
+	if text := call(fs.handleWatchList, map[string]interface{}{}); !strings.Contains(text, `"snapshots": 2`) {
+		t.Errorf("expected persisted snapshots, got %s", text)
+	}
+}
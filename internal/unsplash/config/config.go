@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"time"
+
+	"github.com/dyike/MonoMCPHub/pkg/httpclient"
 )
 
 // Config holds the application configuration
@@ -11,6 +13,9 @@ type Config struct {
 	// Unsplash API settings
 	UnsplashAPIKey string
 	Timeout        time.Duration
+
+	// HTTP overrides the outbound HTTP settings, UNSPLASH_PROXY sets its proxy
+	HTTP *httpclient.Config
 }
 
 // Load reads configuration from a YAML file
@@ -23,6 +28,12 @@ func Load(path string) (*Config, error) {
 	cfg := &Config{
 		UnsplashAPIKey: apiKey,
 		Timeout:        30 * time.Second,
+		HTTP: &httpclient.Config{
+			Proxy: os.Getenv("UNSPLASH_PROXY"),
+		},
+	}
+	if _, err := httpclient.NewTransport(httpclient.Resolve(cfg.HTTP)); err != nil {
+		return nil, fmt.Errorf("invalid HTTP settings: %v", err)
 	}
 	return cfg, nil
 }
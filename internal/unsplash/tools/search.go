@@ -47,6 +47,7 @@ func HandleSearchPhotos(cfg *config.Config) func(context.Context, mcp.CallToolRe
 		conf := &unsplash.UnsplashConfig{
 			AccessKey: cfg.UnsplashAPIKey,
 			Timeout:   cfg.Timeout,
+			HTTP:      cfg.HTTP,
 		}
 		client := unsplash.NewUnsplashClient(conf)
 
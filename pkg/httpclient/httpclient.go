@@ -0,0 +1,251 @@
+// Package httpclient builds the outbound HTTP clients of all services, so proxy
+// and certificate settings are configured once for the whole hub.
+package httpclient
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Config describes how requests leave the machine. The process-wide settings
+// come from the environment, services may override single fields.
+type Config struct {
+	// Proxy is an http, https or socks5 proxy URL used for every request. When
+	// empty the standard HTTP_PROXY and HTTPS_PROXY variables apply.
+	Proxy string `json:"proxy"`
+	// NoProxy lists hosts, domain suffixes and CIDR ranges that are reached
+	// directly, in NO_PROXY syntax.
+	NoProxy []string `json:"no_proxy"`
+	// CABundle is a PEM file of root certificates trusted in addition to the
+	// system pool, for proxies that intercept TLS.
+	CABundle string `json:"ca_bundle"`
+}
+
+// FromEnvironment returns the process-wide settings: MCP_PROXY or ALL_PROXY,
+// NO_PROXY and MCP_CA_BUNDLE.
+func FromEnvironment() *Config {
+	cfg := &Config{
+		Proxy:    getenv("MCP_PROXY", "ALL_PROXY", "all_proxy"),
+		CABundle: getenv("MCP_CA_BUNDLE"),
+	}
+	if noProxy := getenv("NO_PROXY", "no_proxy"); noProxy != "" {
+		cfg.NoProxy = strings.Split(noProxy, ",")
+	}
+	return cfg
+}
+
+// Resolve returns the environment settings with the non-empty fields of a
+// service override applied. override may be nil.
+func Resolve(override *Config) *Config {
+	cfg := FromEnvironment()
+	if override == nil {
+		return cfg
+	}
+	if override.Proxy != "" {
+		cfg.Proxy = override.Proxy
+	}
+	if len(override.NoProxy) > 0 {
+		cfg.NoProxy = override.NoProxy
+	}
+	if override.CABundle != "" {
+		cfg.CABundle = override.CABundle
+	}
+	return cfg
+}
+
+func getenv(keys ...string) string {
+	for _, key := range keys {
+		if v := strings.TrimSpace(os.Getenv(key)); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func (c *Config) proxyConfig() *httpproxy.Config {
+	pc := httpproxy.FromEnvironment()
+	if c.Proxy != "" {
+		pc.HTTPProxy = c.Proxy
+		pc.HTTPSProxy = c.Proxy
+	}
+	if len(c.NoProxy) > 0 {
+		pc.NoProxy = strings.Join(c.NoProxy, ",")
+	}
+	return pc
+}
+
+// ProxyURL returns the proxy used for HTTPS requests, or nil when there is none
+func (c *Config) ProxyURL() (*url.URL, error) {
+	pc := c.proxyConfig()
+	raw := pc.HTTPSProxy
+	if raw == "" {
+		raw = pc.HTTPProxy
+	}
+	if raw == "" {
+		return nil, nil
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %v", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	return u, nil
+}
+
+// ProxyHosts returns the host names of the configured proxies
+func (c *Config) ProxyHosts() []string {
+	var hosts []string
+	pc := c.proxyConfig()
+	for _, raw := range []string{pc.HTTPProxy, pc.HTTPSProxy} {
+		if raw == "" {
+			continue
+		}
+		if !strings.Contains(raw, "://") {
+			raw = "http://" + raw
+		}
+		if u, err := url.Parse(raw); err == nil && u.Hostname() != "" {
+			hosts = append(hosts, u.Hostname())
+		}
+	}
+	return hosts
+}
+
+// TLSConfig returns the TLS settings with the CA bundle added, or nil when no
+// bundle is configured.
+func (c *Config) TLSConfig() (*tls.Config, error) {
+	if c.CABundle == "" {
+		return nil, nil
+	}
+	pem, err := os.ReadFile(c.CABundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %v", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in CA bundle %s", c.CABundle)
+	}
+	return &tls.Config{RootCAs: pool}, nil
+}
+
+// NewTransport returns a copy of the default transport with the proxy, no-proxy
+// and CA settings of cfg applied.
+func NewTransport(cfg *Config) (*http.Transport, error) {
+	if cfg == nil {
+		cfg = Resolve(nil)
+	}
+	if _, err := cfg.ProxyURL(); err != nil {
+		return nil, err
+	}
+	tlsConfig, err := cfg.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := cfg.proxyConfig().ProxyFunc()
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	return transport, nil
+}
+
+// NewClient returns a client using NewTransport
+func NewClient(cfg *Config, timeout time.Duration) (*http.Client, error) {
+	transport, err := NewTransport(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+	}, nil
+}
+
+// ChromeFlags returns the proxy-server and proxy-bypass-list values for
+// launching Chrome with the same proxy, empty when no proxy is set. Chrome
+// has no flag for proxy credentials, those stay unsupported.
+func (c *Config) ChromeFlags() (server, bypass string, err error) {
+	u, err := c.ProxyURL()
+	if err != nil || u == nil {
+		return "", "", err
+	}
+	scheme := u.Scheme
+	if scheme == "socks5h" {
+		scheme = "socks5"
+	}
+	server = scheme + "://" + u.Host
+
+	var rules []string
+	for _, np := range strings.Split(c.proxyConfig().NoProxy, ",") {
+		np = strings.TrimSpace(np)
+		switch {
+		case np == "":
+		case np == "*":
+			rules = append(rules, "*")
+		case strings.HasPrefix(np, "."):
+			rules = append(rules, "*"+np)
+		default:
+			rules = append(rules, np)
+		}
+	}
+	return server, strings.Join(rules, ";"), nil
+}
+
+// ChromeSPKIList returns the ignore-certificate-errors-spki-list value that
+// makes Chrome accept chains containing a certificate of the CA bundle, empty
+// when no bundle is configured. Chrome cannot add roots from a flag, so this
+// is how it trusts the same CAs.
+func (c *Config) ChromeSPKIList() (string, error) {
+	if c.CABundle == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(c.CABundle)
+	if err != nil {
+		return "", fmt.Errorf("failed to read CA bundle: %v", err)
+	}
+	var hashes []string
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return "", fmt.Errorf("invalid certificate in CA bundle %s: %v", c.CABundle, err)
+		}
+		sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		hashes = append(hashes, base64.StdEncoding.EncodeToString(sum[:]))
+	}
+	if len(hashes) == 0 {
+		return "", fmt.Errorf("no certificates found in CA bundle %s", c.CABundle)
+	}
+	return strings.Join(hashes, ","), nil
+}
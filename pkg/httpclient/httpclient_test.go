@@ -0,0 +1,125 @@
+package httpclient
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func clearProxyEnv(t *testing.T) {
+	for _, key := range []string{"MCP_PROXY", "ALL_PROXY", "all_proxy", "HTTP_PROXY", "http_proxy", "HTTPS_PROXY", "https_proxy", "NO_PROXY", "no_proxy", "MCP_CA_BUNDLE"} {
+		t.Setenv(key, "")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	clearProxyEnv(t)
+	t.Setenv("ALL_PROXY", "socks5://proxy.corp:1080")
+	t.Setenv("NO_PROXY", "localhost,.corp")
+
+	cfg := Resolve(nil)
+	if cfg.Proxy != "socks5://proxy.corp:1080" || len(cfg.NoProxy) != 2 {
+		t.Errorf("unexpected environment config: %+v", cfg)
+	}
+	cfg = Resolve(&Config{Proxy: "http://other:3128"})
+	if cfg.Proxy != "http://other:3128" || len(cfg.NoProxy) != 2 {
+		t.Errorf("expected override of the proxy only: %+v", cfg)
+	}
+
+	server, bypass, err := cfg.ChromeFlags()
+	if err != nil || server != "http://other:3128" || bypass != "localhost;*.corp" {
+		t.Errorf("unexpected Chrome flags: %q %q %v", server, bypass, err)
+	}
+	if _, err := NewTransport(&Config{Proxy: "ftp://proxy:21"}); err == nil {
+		t.Errorf("expected unsupported proxy scheme to be rejected")
+	}
+}
+
+func TestClientUsesProxy(t *testing.T) {
+	clearProxyEnv(t)
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "proxied "+r.URL.String())
+	}))
+	defer proxy.Close()
+
+	client, err := NewClient(&Config{Proxy: proxy.URL, NoProxy: []string{"direct.test"}}, 5*time.Second)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	resp, err := client.Get("http://upstream.test/path")
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "proxied http://upstream.test/path" {
+		t.Errorf("expected request to go through the proxy, got %q", body)
+	}
+
+	if _, err := client.Get("http://direct.test/"); err == nil {
+		t.Errorf("expected no-proxy host to be dialed directly and fail")
+	}
+}
+
+func TestCABundle(t *testing.T) {
+	clearProxyEnv(t)
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer ts.Close()
+
+	client, _ := NewClient(&Config{}, 5*time.Second)
+	if _, err := client.Get(ts.URL); err == nil {
+		t.Fatalf("expected the test certificate to be untrusted by default")
+	}
+
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(bundle, pemCertificate(ts), 0600); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(&Config{CABundle: bundle}, 5*time.Second)
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("expected the CA bundle to be trusted: %v", err)
+	}
+	resp.Body.Close()
+
+	if _, err := NewClient(&Config{CABundle: filepath.Join(t.TempDir(), "missing.pem")}, 0); err == nil {
+		t.Errorf("expected a missing CA bundle to be rejected")
+	}
+}
+
+func TestChromeSPKIList(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	if list, err := (&Config{}).ChromeSPKIList(); list != "" || err != nil {
+		t.Errorf("expected nothing without a CA bundle, got %q, %v", list, err)
+	}
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(bundle, pemCertificate(ts), 0600); err != nil {
+		t.Fatal(err)
+	}
+	list, err := (&Config{CABundle: bundle}).ChromeSPKIList()
+	if err != nil {
+		t.Fatalf("failed to hash CA bundle: %v", err)
+	}
+	sum := sha256.Sum256(ts.Certificate().RawSubjectPublicKeyInfo)
+	if want := base64.StdEncoding.EncodeToString(sum[:]); list != want {
+		t.Errorf("expected %s, got %s", want, list)
+	}
+}
+
+func pemCertificate(ts *httptest.Server) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ts.Certificate().Raw})
+}
@@ -11,6 +11,8 @@ import (
 	"os"
 	"strconv"
 	"strings"
+
+	"github.com/dyike/MonoMCPHub/pkg/httpclient"
 )
 
 // 定义 API 响应的结构体
@@ -38,8 +40,17 @@ type Position struct {
 	Y float64 `json:"y"`
 }
 
-// 处理图像
+// 处理图像，使用全局的出站 HTTP 配置（代理、CA 证书）
 func ProcessImage(imagePath, apiURL string, boxThreshold, iouThreshold float64, usePaddleOCR bool, imgsz int) (APIResponse, error) {
+	client, err := httpclient.NewClient(httpclient.Resolve(nil), 0)
+	if err != nil {
+		return APIResponse{}, fmt.Errorf("无法创建 HTTP 客户端: %v", err)
+	}
+	return ProcessImageWithClient(client, imagePath, apiURL, boxThreshold, iouThreshold, usePaddleOCR, imgsz)
+}
+
+// 使用指定的 HTTP 客户端处理图像
+func ProcessImageWithClient(client *http.Client, imagePath, apiURL string, boxThreshold, iouThreshold float64, usePaddleOCR bool, imgsz int) (APIResponse, error) {
 	// 打开图片文件
 	file, err := os.Open(imagePath)
 	if err != nil {
@@ -72,7 +83,7 @@ func ProcessImage(imagePath, apiURL string, boxThreshold, iouThreshold float64,
 	}
 
 	// 发送 POST 请求
-	resp, err := http.Post(apiURL, writer.FormDataContentType(), body) // ignore_security_alert
+	resp, err := client.Post(apiURL, writer.FormDataContentType(), body) // ignore_security_alert
 	if err != nil {
 		return APIResponse{}, fmt.Errorf("请求失败: %v", err)
 	}
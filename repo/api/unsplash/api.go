@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"time"
 
+	"github.com/dyike/MonoMCPHub/pkg/httpclient"
 	models "github.com/dyike/MonoMCPHub/repo/models/unsplash"
 )
 
@@ -30,16 +31,23 @@ type UnsplashClient struct {
 type UnsplashConfig struct {
 	AccessKey string
 	Timeout   time.Duration
+	// HTTP overrides the process-wide outbound HTTP settings
+	HTTP *httpclient.Config
 }
 
 // NewUnsplashClient creates a new Unsplash API client
 func NewUnsplashClient(cfg *UnsplashConfig) *UnsplashClient {
+	timeout := time.Duration(cfg.Timeout) * time.Second
+	client, err := httpclient.NewClient(httpclient.Resolve(cfg.HTTP), timeout)
+	if err != nil {
+		// The settings are validated when the config is loaded, fall back to a
+		// direct connection rather than failing every request.
+		client = &http.Client{Timeout: timeout}
+	}
 	return &UnsplashClient{
 		apiKey:  cfg.AccessKey,
 		baseURL: UnsplashAPIEndpoint,
-		client: &http.Client{
-			Timeout: time.Duration(cfg.Timeout) * time.Second,
-		},
+		client:  client,
 	}
 }
 